@@ -0,0 +1,347 @@
+package Handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+const (
+	maxPostAttachments = 10
+	maxCaptionLength   = 500
+	maxAltTextLength   = 300
+)
+
+var (
+	errUnsupportedMedia = fmt.Errorf("unsupported file type")
+	errTooManyMedia     = fmt.Errorf("a post can have at most %d attachments", maxPostAttachments)
+)
+
+type attachmentMeta struct {
+	ID      int     `json:"id"`
+	Caption *string `json:"caption"`
+	AltText *string `json:"alt_text"`
+}
+
+func uploadedMediaFiles(c *gin.Context) []*multipart.FileHeader {
+	if c.Request.MultipartForm == nil {
+		return nil
+	}
+	return c.Request.MultipartForm.File["media"]
+}
+
+func validateAttachmentText(caption string, altText string) error {
+	if len(caption) > maxCaptionLength {
+		return fmt.Errorf("caption must be at most %d characters", maxCaptionLength)
+	}
+	if len(altText) > maxAltTextLength {
+		return fmt.Errorf("alt text must be at most %d characters", maxAltTextLength)
+	}
+	return nil
+}
+
+func mediaTypeForFile(filename string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, format := range mediaFormats {
+		for _, e := range format.Exts {
+			if e == ext {
+				return format.MediaType, nil
+			}
+		}
+	}
+	return "", errUnsupportedMedia
+}
+
+func validateUploadedMedia(c *gin.Context, files []*multipart.FileHeader, uploadCount int) error {
+	if len(files)+uploadCount > maxPostAttachments {
+		return errTooManyMedia
+	}
+	for _, file := range files {
+		if _, err := mediaTypeForFile(file.Filename); err != nil {
+			return err
+		}
+	}
+	captions := c.PostFormArray("caption")
+	altTexts := c.PostFormArray("alt_text")
+	for i := 0; i < len(files)+uploadCount; i++ {
+		var caption, altText string
+		if i < len(captions) {
+			caption = strings.TrimSpace(captions[i])
+		}
+		if i < len(altTexts) {
+			altText = strings.TrimSpace(altTexts[i])
+		}
+		if err := validateAttachmentText(caption, altText); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveUploadedMedia(file *multipart.FileHeader, private bool) (*Models.PostAttachments, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return storeMediaFile(src, file.Size, file.Filename, private, false)
+}
+
+func storeMediaFile(src io.ReaderAt, size int64, filename string, private bool, resumable bool) (*Models.PostAttachments, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	inspected, err := inspectMedia(src, size, ext, resumable)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	var content io.Reader
+	if inspected.Sanitized != nil {
+		hash.Write(inspected.Sanitized)
+		content, size = bytes.NewReader(inspected.Sanitized), int64(len(inspected.Sanitized))
+	} else {
+		if _, err := io.Copy(hash, io.NewSectionReader(src, 0, size)); err != nil {
+			return nil, err
+		}
+		content = io.NewSectionReader(src, 0, size)
+	}
+
+	key := mediaKey(private, uuid.New().String()+ext)
+	if err := mediaStorage.Put(context.Background(), key, content, size, inspected.Format.MimeType); err != nil {
+		return nil, err
+	}
+
+	return &Models.PostAttachments{
+		URL:            publicURLForKey(key),
+		StorageKey:     key,
+		MediaType:      inspected.Format.MediaType,
+		MimeType:       inspected.Format.MimeType,
+		SizeBytes:      size,
+		Checksum:       hex.EncodeToString(hash.Sum(nil)),
+		Width:          inspected.Width,
+		Height:         inspected.Height,
+		Duration:       inspected.Duration,
+		CreatedAt:      time.Now(),
+		VariantsStatus: initialVariantsStatus(inspected.Format),
+	}, nil
+}
+
+func saveUploadedAttachments(c *gin.Context, files []*multipart.FileHeader, uploads []Models.MediaUploads, private bool) ([]Models.PostAttachments, error) {
+	captions := c.PostFormArray("caption")
+	altTexts := c.PostFormArray("alt_text")
+
+	attachments := make([]Models.PostAttachments, 0, len(files)+len(uploads))
+	for i := 0; i < len(files)+len(uploads); i++ {
+		var attachment *Models.PostAttachments
+		var err error
+		if i < len(files) {
+			attachment, err = saveUploadedMedia(files[i], private)
+		} else {
+			attachment, err = saveCompletedUpload(uploads[i-len(files)], private)
+		}
+		if err != nil {
+			removeAttachmentFiles(attachments)
+			return nil, err
+		}
+		if i < len(captions) {
+			attachment.Caption = strings.TrimSpace(captions[i])
+		}
+		if i < len(altTexts) {
+			attachment.AltText = strings.TrimSpace(altTexts[i])
+		}
+		attachments = append(attachments, *attachment)
+	}
+	return attachments, nil
+}
+
+func removeAttachmentFiles(attachments []Models.PostAttachments) {
+	deleteStoredMedia(attachmentKeys(attachments))
+}
+
+func parseAttachmentMeta(raw string) ([]attachmentMeta, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var meta []attachmentMeta
+	if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+		return nil, fmt.Errorf("attachments must be a JSON array")
+	}
+	return meta, nil
+}
+
+func insertAttachments(tx *pg.Tx, postID int, attachments []Models.PostAttachments) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+	for i := range attachments {
+		attachments[i].PostID = postID
+	}
+	_, err := tx.Model(&attachments).Insert()
+	return err
+}
+
+func syncPostCover(tx *pg.Tx, postID int) error {
+	var cover Models.PostAttachments
+	err := tx.Model(&cover).
+		Where("post_id = ?", postID).
+		Order("position ASC").
+		Limit(1).
+		Select()
+	if err != nil && err != pg.ErrNoRows {
+		return err
+	}
+	_, err = tx.Model((*Models.Posts)(nil)).
+		Set("media_url = ?", cover.URL).
+		Set("media_type = ?", cover.MediaType).
+		Where("id = ?", postID).
+		Update()
+	return err
+}
+
+func loadAttachments(db *pg.DB, postIDs []int) (map[int][]Models.PostAttachments, error) {
+	result := make(map[int][]Models.PostAttachments)
+	if len(postIDs) == 0 {
+		return result, nil
+	}
+
+	var attachments []Models.PostAttachments
+	err := db.Model(&attachments).
+		Where("post_id IN (?)", pg.In(postIDs)).
+		Order("post_id ASC", "position ASC").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attachments {
+		result[a.PostID] = append(result[a.PostID], a)
+	}
+	if err := attachVariants(db, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func attachMedia(db *pg.DB, posts []Models.PostWithCounts, currentUser uuid.UUID) {
+	postIDs := make([]int, 0, len(posts))
+	forumIDs := make([]uuid.UUID, 0)
+	seenForums := make(map[uuid.UUID]bool)
+	for _, p := range posts {
+		postIDs = append(postIDs, p.ID)
+		if !seenForums[p.ForumID] {
+			seenForums[p.ForumID] = true
+			forumIDs = append(forumIDs, p.ForumID)
+		}
+	}
+	attachments, err := loadAttachments(db, postIDs)
+	if err != nil {
+		return
+	}
+	access := privateMediaAccess(db, currentUser, forumIDs)
+	for i := range posts {
+		posts[i].Attachments = attachments[posts[i].ID]
+		if posts[i].Attachments == nil {
+			posts[i].Attachments = []Models.PostAttachments{}
+			continue
+		}
+		resolveAttachmentURLs(posts[i].Attachments, access[posts[i].ForumID])
+		posts[i].MediaURL = posts[i].Attachments[0].URL
+	}
+}
+
+func planAttachmentChanges(existing []Models.PostAttachments, removeIDs []int, meta []attachmentMeta, addedCount int) ([]Models.PostAttachments, error) {
+	existingIDs := make(map[int]bool, len(existing))
+	for _, a := range existing {
+		existingIDs[a.ID] = true
+	}
+	removed := make(map[int]bool, len(removeIDs))
+	for _, id := range removeIDs {
+		if !existingIDs[id] {
+			return nil, fmt.Errorf("attachment %d does not belong to this post", id)
+		}
+		removed[id] = true
+	}
+
+	byID := make(map[int]Models.PostAttachments, len(existing))
+	for _, a := range existing {
+		if !removed[a.ID] {
+			byID[a.ID] = a
+		}
+	}
+
+	ordered := make([]Models.PostAttachments, 0, len(byID))
+	listed := make(map[int]bool, len(meta))
+	for _, m := range meta {
+		a, ok := byID[m.ID]
+		if !ok || listed[m.ID] {
+			return nil, fmt.Errorf("attachment %d does not belong to this post", m.ID)
+		}
+		listed[m.ID] = true
+		if m.Caption != nil {
+			a.Caption = strings.TrimSpace(*m.Caption)
+		}
+		if m.AltText != nil {
+			a.AltText = strings.TrimSpace(*m.AltText)
+		}
+		if err := validateAttachmentText(a.Caption, a.AltText); err != nil {
+			return nil, err
+		}
+		ordered = append(ordered, a)
+	}
+	for _, a := range existing {
+		if !removed[a.ID] && !listed[a.ID] {
+			ordered = append(ordered, a)
+		}
+	}
+
+	if len(ordered)+addedCount > maxPostAttachments {
+		return nil, errTooManyMedia
+	}
+	for i := range ordered {
+		ordered[i].Position = i + 1
+	}
+	return ordered, nil
+}
+
+func applyAttachmentChanges(tx *pg.Tx, postID int, kept []Models.PostAttachments, removeIDs []int, added []Models.PostAttachments) error {
+	if len(removeIDs) > 0 {
+		_, err := tx.Model((*Models.PostAttachments)(nil)).
+			Where("post_id = ?", postID).
+			Where("id IN (?)", pg.In(removeIDs)).
+			Delete()
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range kept {
+		_, err := tx.Model(&kept[i]).
+			Column("position", "caption", "alt_text").
+			WherePK().
+			Update()
+		if err != nil {
+			return err
+		}
+	}
+	for i := range added {
+		added[i].Position = len(kept) + i + 1
+	}
+	if err := insertAttachments(tx, postID, added); err != nil {
+		return err
+	}
+
+	return syncPostCover(tx, postID)
+}
@@ -0,0 +1,140 @@
+package Handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+func recordAuditLog(db *pg.DB, entry *Models.AuditLogs) {
+	entry.CreatedAt = time.Now()
+	if _, err := db.Model(entry).Insert(); err != nil {
+		log.Printf("Record Audit Log Failed (%s %s:%s): %v", entry.Action, entry.TargetType, entry.TargetID, err)
+	}
+}
+
+func GetAuditLogs(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	isSysAdmin, err := isSystemAdmin(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !isSysAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "Only system admins can view the audit log",
+		})
+		return
+	}
+
+	logs := make([]Models.AuditLogs, 0)
+	query := db.Model(&logs).Relation("Actor.uid").Relation("Actor.username")
+	if forumIDStr := c.Query("forum_id"); forumIDStr != "" {
+		forumID, err := uuid.Parse(forumIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+			return
+		}
+		query.Where("audit_logs.forum_id = ?", forumID)
+	}
+	if !applyAuditLogFilters(c, query) {
+		return
+	}
+	if err := query.Select(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"audit_logs": logs})
+}
+
+func GetForumAuditLogs(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to view this forum's audit log",
+		})
+		return
+	}
+
+	logs := make([]Models.AuditLogs, 0)
+	query := db.Model(&logs).
+		Relation("Actor.uid").
+		Relation("Actor.username").
+		Where("audit_logs.forum_id = ?", forumID)
+	if !applyAuditLogFilters(c, query) {
+		return
+	}
+	if err := query.Select(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"audit_logs": logs})
+}
+
+func applyAuditLogFilters(c *gin.Context, query *pg.Query) bool {
+	if actorIDStr := c.Query("actor_id"); actorIDStr != "" {
+		actorID, err := uuid.Parse(actorIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Actor ID format"})
+			return false
+		}
+		query.Where("audit_logs.actor_id = ?", actorID)
+	}
+	if action := c.Query("action"); action != "" {
+		if strings.HasSuffix(action, ".*") {
+			query.Where("audit_logs.action LIKE ?", strings.TrimSuffix(action, "*")+"%")
+		} else {
+			query.Where("audit_logs.action = ?", action)
+		}
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query.Where("audit_logs.target_type = ?", targetType)
+	}
+	if targetID := c.Query("target_id"); targetID != "" {
+		query.Where("audit_logs.target_id = ?", targetID)
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	query.Order("audit_logs.created_at DESC").Limit(limit).Offset(offset)
+	return true
+}
@@ -0,0 +1,321 @@
+package Handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+func getActiveForumBan(db *pg.DB, forumID uuid.UUID, userID uuid.UUID) (*Models.ForumBans, error) {
+	var ban Models.ForumBans
+	err := db.Model(&ban).
+		Where("forum_id = ?", forumID).
+		Where("user_id = ?", userID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Select()
+	if err == pg.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ban, nil
+}
+
+func getForumIDByPostID(db *pg.DB, postID int) (uuid.UUID, error) {
+	var post Models.Posts
+	err := db.Model(&post).Column("forum_id").Where("id = ?", postID).Select()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return post.ForumID, nil
+}
+
+func upsertForumBan(tx *pg.Tx, ban *Models.ForumBans) error {
+	_, err := tx.Model(ban).
+		OnConflict("(forum_id, user_id) DO UPDATE").
+		Set("type = EXCLUDED.type").
+		Set("reason = EXCLUDED.reason").
+		Set("banned_by = EXCLUDED.banned_by").
+		Set("expires_at = EXCLUDED.expires_at").
+		Set("created_at = EXCLUDED.created_at").
+		Insert()
+	if err != nil {
+		return err
+	}
+
+	if ban.Type == "ban" {
+		_, err = tx.Model((*Models.ForumMembers)(nil)).
+			Where("user_id = ? AND forum_id = ?", ban.UserID, ban.ForumID).
+			Delete()
+	}
+	return err
+}
+
+func forumBanResponse(ban *Models.ForumBans) gin.H {
+	detail := "You are banned from this forum"
+	if ban.Type == "mute" {
+		detail = "You are muted in this forum"
+	}
+	return gin.H{
+		"error":      "Forbidden",
+		"detail":     detail,
+		"type":       ban.Type,
+		"reason":     ban.Reason,
+		"expires_at": ban.ExpiresAt,
+	}
+}
+
+func BanForumUser(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumIDStr := c.Param("forum_id")
+	forumID, err := uuid.Parse(forumIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	forumExists, err := db.Model((*Models.Forums)(nil)).Where("fid = ?", forumID).Exists()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve forum"})
+		return
+	}
+	if !forumExists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forum not found"})
+		return
+	}
+
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to ban users in this forum",
+		})
+		return
+	}
+
+	var payload struct {
+		UserID          uuid.UUID `json:"user_id"`
+		Type            string    `json:"type"`
+		Reason          string    `json:"reason"`
+		DurationMinutes int       `json:"duration_minutes"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+
+	banType := strings.ToLower(strings.TrimSpace(payload.Type))
+	if banType == "" {
+		banType = "ban"
+	}
+	if banType != "ban" && banType != "mute" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Type must be 'ban' or 'mute'"})
+		return
+	}
+	if payload.UserID == uuid.Nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+		return
+	}
+	if payload.UserID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot ban yourself"})
+		return
+	}
+	if payload.DurationMinutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Duration must not be negative"})
+		return
+	}
+
+	targetIsAdmin, err := canModerateForum(db, payload.UserID, forumID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if targetIsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "Forum admins cannot be banned",
+		})
+		return
+	}
+
+	ban := &Models.ForumBans{
+		ForumID:   forumID,
+		UserID:    payload.UserID,
+		Type:      banType,
+		Reason:    strings.TrimSpace(payload.Reason),
+		BannedBy:  userID,
+		CreatedAt: time.Now(),
+	}
+	if payload.DurationMinutes > 0 {
+		expiresAt := time.Now().Add(time.Duration(payload.DurationMinutes) * time.Minute)
+		ban.ExpiresAt = &expiresAt
+	}
+
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		return upsertForumBan(tx, ban)
+	})
+	if err != nil {
+		log.Printf("Ban Forum User Failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ban user", "detail": err.Error()})
+		return
+	}
+	if ban.Type == "ban" {
+		revokeForumSubscriptions(db, forumID, ban.UserID)
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "forum." + banType,
+		TargetType: "user",
+		TargetID:   payload.UserID.String(),
+		After:      ban,
+		Reason:     ban.Reason,
+	})
+	notifyModerationAction(db, userID, payload.UserID, forumID, nil, nil, "forum."+banType, ban.Reason)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "User restricted successfully",
+		"ban":     ban,
+	})
+}
+
+func UnbanForumUser(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumIDStr := c.Param("forum_id")
+	forumID, err := uuid.Parse(forumIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to unban users in this forum",
+		})
+		return
+	}
+
+	var ban Models.ForumBans
+	res, err := db.Model(&ban).
+		Where("forum_id = ? AND user_id = ?", forumID, targetID).
+		Returning("*").
+		Delete()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unban user", "detail": err.Error()})
+		return
+	}
+	if res.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ban not found"})
+		return
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "forum.un" + ban.Type,
+		TargetType: "user",
+		TargetID:   targetID.String(),
+		Before:     ban,
+	})
+	notifyModerationAction(db, userID, targetID, forumID, nil, nil, "forum.un"+ban.Type, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unbanned successfully"})
+}
+
+func GetForumBans(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumIDStr := c.Param("forum_id")
+	forumID, err := uuid.Parse(forumIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to view bans in this forum",
+		})
+		return
+	}
+
+	bans := make([]Models.ForumBans, 0)
+	query := db.Model(&bans).
+		Relation("User.uid").
+		Relation("User.username").
+		Where("forum_bans.forum_id = ?", forumID).
+		Where("forum_bans.expires_at IS NULL OR forum_bans.expires_at > ?", time.Now()).
+		Order("forum_bans.created_at DESC")
+	if banType := c.Query("type"); banType != "" {
+		query.Where("forum_bans.type = ?", banType)
+	}
+	if err := query.Select(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve bans", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bans": bans})
+}
+
+func StartBanExpiryJob(db *pg.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		res, err := db.Model((*Models.ForumBans)(nil)).
+			Where("expires_at IS NOT NULL AND expires_at <= ?", time.Now()).
+			Delete()
+		if err != nil {
+			log.Printf("Ban Expiry Job Failed: %v", err)
+			continue
+		}
+		if res.RowsAffected() > 0 {
+			log.Printf("Ban Expiry Job: removed %d expired bans", res.RowsAffected())
+		}
+	}
+}
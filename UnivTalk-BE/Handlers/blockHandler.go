@@ -0,0 +1,103 @@
+package Handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+func isBlockedBetween(db *pg.DB, userID uuid.UUID, otherID uuid.UUID) (bool, error) {
+	return db.Model((*Models.UserBlocks)(nil)).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", userID, otherID, otherID, userID).
+		Exists()
+}
+
+func BlockUser(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+	if targetID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot block yourself"})
+		return
+	}
+
+	exists, err := db.Model((*Models.Users)(nil)).Where("uid = ?", targetID).Exists()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	block := &Models.UserBlocks{BlockerID: userID, BlockedID: targetID, CreatedAt: time.Now()}
+	if _, err := db.Model(block).OnConflict("DO NOTHING").Insert(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked"})
+}
+
+func UnblockUser(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	res, err := db.Model((*Models.UserBlocks)(nil)).
+		Where("blocker_id = ?", userID).
+		Where("blocked_id = ?", targetID).
+		Delete()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+		return
+	}
+	if res.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not blocked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked"})
+}
+
+func GetBlockedUsers(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	blocks := make([]Models.UserBlocks, 0)
+	err = db.Model(&blocks).
+		Relation("Blocked.uid").
+		Relation("Blocked.username").
+		Where("blocker_id = ?", userID).
+		Order("user_blocks.created_at DESC").
+		Select()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve blocked users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocks": blocks})
+}
@@ -0,0 +1,719 @@
+package Handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	maxBookmarkCollections    = 100
+	maxBookmarksPerCollection = 1000
+	maxCollectionName         = 100
+	defaultBookmarkPageSize   = 50
+	maxBookmarkPageSize       = 200
+)
+
+var (
+	errBookmarkLimitReached = fmt.Errorf("bookmark limit reached")
+	errInvalidBookmarkOrder = fmt.Errorf("bookmark_ids must list every bookmark of this collection exactly once")
+)
+
+func bookmarkPageLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultBookmarkPageSize)))
+	if err != nil || limit <= 0 || limit > maxBookmarkPageSize {
+		limit = defaultBookmarkPageSize
+	}
+	return limit
+}
+
+func isPostSaved(db *pg.DB, userID uuid.UUID, postID int) (bool, error) {
+	return db.Model((*Models.Bookmarks)(nil)).
+		Where("user_id = ?", userID).
+		Where("post_id = ?", postID).
+		Exists()
+}
+
+func attachSaved(db *pg.DB, posts []Models.PostWithCounts, currentUser uuid.UUID) {
+	if currentUser == uuid.Nil || len(posts) == 0 {
+		return
+	}
+	postIDs := make([]int, 0, len(posts))
+	for _, p := range posts {
+		postIDs = append(postIDs, p.ID)
+	}
+	var savedIDs []int
+	err := db.Model((*Models.Bookmarks)(nil)).
+		ColumnExpr("DISTINCT post_id").
+		Where("user_id = ?", currentUser).
+		Where("post_id IN (?)", pg.In(postIDs)).
+		Select(&savedIDs)
+	if err != nil {
+		return
+	}
+	saved := make(map[int]bool, len(savedIDs))
+	for _, id := range savedIDs {
+		saved[id] = true
+	}
+	for i := range posts {
+		posts[i].Saved = saved[posts[i].ID]
+	}
+}
+
+func getBookmarkCollection(c *gin.Context, db *pg.DB, userID uuid.UUID) (*Models.BookmarkCollections, bool) {
+	collectionID, err := strconv.Atoi(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Collection ID format"})
+		return nil, false
+	}
+
+	collection := &Models.BookmarkCollections{}
+	err = db.Model(collection).
+		Where("id = ?", collectionID).
+		Where("user_id = ?", userID).
+		Select()
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve collection"})
+		return nil, false
+	}
+	return collection, true
+}
+
+func bindCollectionName(c *gin.Context) (string, bool) {
+	var payload struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return "", false
+	}
+	name := strings.TrimSpace(payload.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return "", false
+	}
+	if len(name) > maxCollectionName {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Name must be at most %d characters", maxCollectionName)})
+		return "", false
+	}
+	return name, true
+}
+
+func collectionNameTaken(db *pg.DB, userID uuid.UUID, name string, exceptID int) (bool, error) {
+	return db.Model((*Models.BookmarkCollections)(nil)).
+		Where("user_id = ?", userID).
+		Where("LOWER(name) = LOWER(?)", name).
+		Where("id <> ?", exceptID).
+		Exists()
+}
+
+func GetBookmarkCollections(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	collections := make([]Models.BookmarkCollections, 0)
+	err = db.Model(&collections).
+		Where("user_id = ?", userID).
+		Order("position ASC", "id ASC").
+		Select()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve collections"})
+		return
+	}
+
+	type ItemCount struct {
+		CollectionID int
+		ItemCount    int
+	}
+	var counts []ItemCount
+	err = db.Model((*Models.Bookmarks)(nil)).
+		Column("collection_id").
+		ColumnExpr("COUNT(*) AS item_count").
+		Where("user_id = ?", userID).
+		Group("collection_id").
+		Select(&counts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve collections"})
+		return
+	}
+	countMap := make(map[int]int, len(counts))
+	for _, row := range counts {
+		countMap[row.CollectionID] = row.ItemCount
+	}
+	for i := range collections {
+		collections[i].ItemCount = countMap[collections[i].ID]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collections": collections})
+}
+
+func CreateBookmarkCollection(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	name, ok := bindCollectionName(c)
+	if !ok {
+		return
+	}
+
+	count, err := db.Model((*Models.BookmarkCollections)(nil)).
+		Where("user_id = ?", userID).
+		Count()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve collections"})
+		return
+	}
+	if count >= maxBookmarkCollections {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("You can have at most %d collections", maxBookmarkCollections)})
+		return
+	}
+	taken, err := collectionNameTaken(db, userID, name, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve collections"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "A collection with this name already exists"})
+		return
+	}
+
+	var lastPosition int
+	err = db.Model((*Models.BookmarkCollections)(nil)).
+		ColumnExpr("COALESCE(MAX(position), 0)").
+		Where("user_id = ?", userID).
+		Select(&lastPosition)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create collection"})
+		return
+	}
+
+	collection := &Models.BookmarkCollections{
+		UserID:   userID,
+		Name:     name,
+		Position: lastPosition + 1,
+	}
+	if _, err := db.Model(collection).Returning("*").Insert(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create collection", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Collection created", "collection": collection})
+}
+
+func UpdateBookmarkCollection(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	collection, ok := getBookmarkCollection(c, db, userID)
+	if !ok {
+		return
+	}
+	name, ok := bindCollectionName(c)
+	if !ok {
+		return
+	}
+
+	taken, err := collectionNameTaken(db, userID, name, collection.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve collections"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "A collection with this name already exists"})
+		return
+	}
+
+	_, err = db.Model(collection).
+		Set("name = ?", name).
+		Set("updated_at = CURRENT_TIMESTAMP").
+		WherePK().
+		Returning("*").
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection updated", "collection": collection})
+}
+
+func DeleteBookmarkCollection(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	collection, ok := getBookmarkCollection(c, db, userID)
+	if !ok {
+		return
+	}
+
+	if _, err := db.Model(collection).WherePK().Delete(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection deleted"})
+}
+
+func ReorderBookmarkCollections(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var payload struct {
+		CollectionIDs []int `json:"collection_ids"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+
+	var existingIDs []int
+	err = db.Model((*Models.BookmarkCollections)(nil)).
+		Column("id").
+		Where("user_id = ?", userID).
+		Select(&existingIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve collections"})
+		return
+	}
+	if !isCompleteOrder(existingIDs, payload.CollectionIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Invalid collection order",
+			"detail": "collection_ids must list every one of your collections exactly once",
+		})
+		return
+	}
+
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		for i, id := range payload.CollectionIDs {
+			_, err := tx.Model((*Models.BookmarkCollections)(nil)).
+				Set("position = ?", i+1).
+				Where("id = ?", id).
+				Update()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder collections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collections reordered", "collection_ids": payload.CollectionIDs})
+}
+
+func isCompleteOrder(existing []int, ordered []int) bool {
+	existingSet := make(map[int]bool, len(existing))
+	for _, id := range existing {
+		existingSet[id] = true
+	}
+	seen := make(map[int]bool, len(ordered))
+	for _, id := range ordered {
+		if !existingSet[id] || seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	return len(seen) == len(existingSet)
+}
+
+func hydrateBookmarks(db *pg.DB, userID uuid.UUID, bookmarks []Models.Bookmarks) error {
+	postIDs := make([]int, 0)
+	commentIDs := make([]int, 0)
+	for _, b := range bookmarks {
+		if b.PostID != nil {
+			postIDs = append(postIDs, *b.PostID)
+		}
+		if b.CommentID != nil {
+			commentIDs = append(commentIDs, *b.CommentID)
+		}
+	}
+
+	visible := make(map[uuid.UUID]bool)
+	canView := func(forumID uuid.UUID) bool {
+		allowed, checked := visible[forumID]
+		if !checked {
+			allowed, _ = canViewForum(db, userID, forumID)
+			visible[forumID] = allowed
+		}
+		return allowed
+	}
+
+	posts, err := loadPostsWithCounts(db, postIDs, userID)
+	if err != nil {
+		return err
+	}
+	postMap := make(map[int]*Models.PostWithCounts, len(posts))
+	for i := range posts {
+		if canView(posts[i].ForumID) {
+			postMap[posts[i].ID] = &posts[i]
+		}
+	}
+
+	commentMap := make(map[int]*Models.Comments)
+	if len(commentIDs) > 0 {
+		var comments []Models.Comments
+		err := db.Model(&comments).
+			Relation("User.uid").
+			Relation("User.username").
+			Where("comments.id IN (?)", pg.In(commentIDs)).
+			Select()
+		if err != nil {
+			return err
+		}
+		parentIDs := make([]int, 0, len(comments))
+		for _, cmt := range comments {
+			parentIDs = append(parentIDs, cmt.PostID)
+		}
+		var parents []Models.Posts
+		if len(parentIDs) > 0 {
+			err := db.Model(&parents).
+				Column("id", "forum_id").
+				Where("id IN (?)", pg.In(parentIDs)).
+				Select()
+			if err != nil {
+				return err
+			}
+		}
+		parentForums := make(map[int]uuid.UUID, len(parents))
+		for _, p := range parents {
+			parentForums[p.ID] = p.ForumID
+		}
+		for i := range comments {
+			forumID, ok := parentForums[comments[i].PostID]
+			if ok && canView(forumID) {
+				commentMap[comments[i].ID] = &comments[i]
+			}
+		}
+	}
+
+	for i := range bookmarks {
+		if bookmarks[i].PostID != nil {
+			bookmarks[i].Post = postMap[*bookmarks[i].PostID]
+		}
+		if bookmarks[i].CommentID != nil {
+			bookmarks[i].Comment = commentMap[*bookmarks[i].CommentID]
+		}
+	}
+	return nil
+}
+
+func GetBookmarks(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	collection, ok := getBookmarkCollection(c, db, userID)
+	if !ok {
+		return
+	}
+
+	limit := bookmarkPageLimit(c)
+	bookmarks := make([]Models.Bookmarks, 0)
+	query := db.Model(&bookmarks).Where("collection_id = ?", collection.ID)
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := strconv.Atoi(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		query.Where("position > ?", after)
+	}
+	if err := query.Order("position ASC", "id ASC").Limit(limit + 1).Select(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve bookmarks"})
+		return
+	}
+
+	nextCursor := ""
+	if len(bookmarks) > limit {
+		bookmarks = bookmarks[:limit]
+		nextCursor = strconv.Itoa(bookmarks[limit-1].Position)
+	}
+	if err := hydrateBookmarks(db, userID, bookmarks); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve bookmarks", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collection": collection, "bookmarks": bookmarks, "next_cursor": nextCursor})
+}
+
+func bookmarkTargetForum(db *pg.DB, postID *int, commentID *int) (uuid.UUID, error) {
+	if commentID != nil {
+		comment := &Models.Comments{}
+		if err := db.Model(comment).Column("post_id").Where("id = ?", *commentID).Select(); err != nil {
+			return uuid.Nil, err
+		}
+		postID = &comment.PostID
+	}
+	post := &Models.Posts{}
+	if err := db.Model(post).Column("forum_id").Where("id = ?", *postID).Select(); err != nil {
+		return uuid.Nil, err
+	}
+	return post.ForumID, nil
+}
+
+func SaveBookmark(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	collection, ok := getBookmarkCollection(c, db, userID)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		PostID    *int `json:"post_id"`
+		CommentID *int `json:"comment_id"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+	if (payload.PostID == nil) == (payload.CommentID == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either post_id or comment_id"})
+		return
+	}
+
+	forumID, err := bookmarkTargetForum(db, payload.PostID, payload.CommentID)
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Content not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve content"})
+		return
+	}
+	allowed, err := canViewForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum access"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Content not found"})
+		return
+	}
+
+	bookmark := &Models.Bookmarks{
+		CollectionID: collection.ID,
+		UserID:       userID,
+		PostID:       payload.PostID,
+		CommentID:    payload.CommentID,
+	}
+	existing := &Models.Bookmarks{}
+	alreadySaved := false
+	// Locking the collection row serializes saves into the same collection,
+	// so the duplicate check and the next position cannot race.
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		err := tx.Model(collection).WherePK().For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+		query := tx.Model(existing).Where("collection_id = ?", collection.ID)
+		if payload.PostID != nil {
+			query.Where("post_id = ?", *payload.PostID)
+		} else {
+			query.Where("comment_id = ?", *payload.CommentID)
+		}
+		err = query.Select()
+		if err == nil {
+			alreadySaved = true
+			return nil
+		}
+		if err != pg.ErrNoRows {
+			return err
+		}
+
+		var count, lastPosition int
+		err = tx.Model((*Models.Bookmarks)(nil)).
+			ColumnExpr("COUNT(*)").
+			ColumnExpr("COALESCE(MAX(position), 0)").
+			Where("collection_id = ?", collection.ID).
+			Select(&count, &lastPosition)
+		if err != nil {
+			return err
+		}
+		if count >= maxBookmarksPerCollection {
+			return errBookmarkLimitReached
+		}
+		bookmark.Position = lastPosition + 1
+		_, err = tx.Model(bookmark).Returning("*").Insert()
+		return err
+	})
+	if err == errBookmarkLimitReached {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A collection can hold at most %d items", maxBookmarksPerCollection)})
+		return
+	}
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save bookmark", "detail": err.Error()})
+		return
+	}
+	if alreadySaved {
+		c.JSON(http.StatusOK, gin.H{"message": "Already saved", "bookmark": existing})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Saved", "bookmark": bookmark})
+}
+
+func RemoveBookmark(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	collection, ok := getBookmarkCollection(c, db, userID)
+	if !ok {
+		return
+	}
+	bookmarkID, err := strconv.Atoi(c.Param("bookmark_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Bookmark ID format"})
+		return
+	}
+
+	res, err := db.Model((*Models.Bookmarks)(nil)).
+		Where("id = ?", bookmarkID).
+		Where("collection_id = ?", collection.ID).
+		Delete()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove bookmark"})
+		return
+	}
+	if res.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark removed"})
+}
+
+func ReorderBookmarks(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	collection, ok := getBookmarkCollection(c, db, userID)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		BookmarkIDs []int `json:"bookmark_ids"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		err := tx.Model(collection).WherePK().For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+		var existingIDs []int
+		err = tx.Model((*Models.Bookmarks)(nil)).
+			Column("id").
+			Where("collection_id = ?", collection.ID).
+			Select(&existingIDs)
+		if err != nil {
+			return err
+		}
+		if !isCompleteOrder(existingIDs, payload.BookmarkIDs) {
+			return errInvalidBookmarkOrder
+		}
+		for i, id := range payload.BookmarkIDs {
+			_, err := tx.Model((*Models.Bookmarks)(nil)).
+				Set("position = ?", i+1).
+				Where("id = ?", id).
+				Update()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == errInvalidBookmarkOrder {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark order", "detail": err.Error()})
+		return
+	}
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder bookmarks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmarks reordered", "bookmark_ids": payload.BookmarkIDs})
+}
+
+func UnsaveContent(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Model((*Models.Bookmarks)(nil)).Where("user_id = ?", userID)
+	postIDStr, commentIDStr := c.Query("post_id"), c.Query("comment_id")
+	switch {
+	case postIDStr != "" && commentIDStr == "":
+		postID, err := strconv.Atoi(postIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID format"})
+			return
+		}
+		query.Where("post_id = ?", postID)
+	case commentIDStr != "" && postIDStr == "":
+		commentID, err := strconv.Atoi(commentIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Comment ID format"})
+			return
+		}
+		query.Where("comment_id = ?", commentID)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either post_id or comment_id"})
+		return
+	}
+
+	res, err := query.Delete()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove bookmarks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Removed from all collections", "removed": res.RowsAffected()})
+}
@@ -0,0 +1,29 @@
+package Handlers
+
+import "testing"
+
+func TestIsCompleteOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []int
+		ordered  []int
+		want     bool
+	}{
+		{"same order", []int{1, 2, 3}, []int{1, 2, 3}, true},
+		{"reordered", []int{1, 2, 3}, []int{3, 1, 2}, true},
+		{"both empty", nil, []int{}, true},
+		{"missing id", []int{1, 2, 3}, []int{3, 1}, false},
+		{"unknown id", []int{1, 2, 3}, []int{1, 2, 4}, false},
+		{"extra id", []int{1, 2}, []int{1, 2, 3}, false},
+		{"duplicate id", []int{1, 2, 3}, []int{1, 1, 2, 3}, false},
+		{"duplicate replaces missing", []int{1, 2, 3}, []int{1, 2, 2}, false},
+		{"empty order for non-empty collection", []int{1}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCompleteOrder(tt.existing, tt.ordered); got != tt.want {
+				t.Errorf("isCompleteOrder(%v, %v) = %v, want %v", tt.existing, tt.ordered, got, tt.want)
+			}
+		})
+	}
+}
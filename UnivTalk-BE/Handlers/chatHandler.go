@@ -0,0 +1,408 @@
+package Handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	maxChatMessageLength = 1000
+	chatBackfillSize     = 50
+	maxChatBackfill      = 200
+	chatRateLimit        = 5
+	chatRateWindow       = 10 * time.Second
+	chatTypingInterval   = 3 * time.Second
+)
+
+var (
+	errChatMessageNotFound = errors.New("message not found")
+	errChatDeleteForbidden = errors.New("you can only delete your own messages")
+	errChatRateLimited     = errors.New("chat rate limit exceeded")
+)
+
+func chatTopic(forumID uuid.UUID) string { return "chat:" + forumID.String() }
+
+func isForumChatMember(db *pg.DB, userID uuid.UUID, forumID uuid.UUID) (bool, error) {
+	return db.Model((*Models.ForumMembers)(nil)).
+		Where("forum_id = ?", forumID).
+		Where("user_id = ?", userID).
+		Exists()
+}
+
+// canReadForumChat lets members and the forum's moderators read the chat,
+// over both the REST history and the WebSocket.
+func canReadForumChat(db *pg.DB, userID uuid.UUID, forumID uuid.UUID) (bool, error) {
+	isMember, err := isForumChatMember(db, userID, forumID)
+	if err != nil || isMember {
+		return isMember, err
+	}
+	return canModerateForum(db, userID, forumID)
+}
+
+func loadChatMessages(db *pg.DB, forumID uuid.UUID, before int, after int, limit int) ([]Models.ForumChatMessages, error) {
+	messages := make([]Models.ForumChatMessages, 0)
+	query := db.Model(&messages).
+		Relation("User.uid").
+		Relation("User.username").
+		Where("forum_chat_messages.forum_id = ?", forumID)
+	if after > 0 {
+		query.Where("forum_chat_messages.id > ?", after).Order("forum_chat_messages.id ASC")
+	} else {
+		if before > 0 {
+			query.Where("forum_chat_messages.id < ?", before)
+		}
+		query.Order("forum_chat_messages.id DESC")
+	}
+	err := query.Limit(limit).Select()
+	return messages, err
+}
+
+func checkChatSender(db *pg.DB, userID uuid.UUID, forumID uuid.UUID) (gin.H, error) {
+	isMember, err := isForumChatMember(db, userID, forumID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return gin.H{"error": "Only forum members can chat"}, nil
+	}
+	ban, err := getActiveForumBan(db, forumID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if ban != nil {
+		return forumBanResponse(ban), nil
+	}
+	return nil, nil
+}
+
+func sendChatMessage(db *pg.DB, forumID uuid.UUID, user *Models.Users, body string) (gin.H, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return gin.H{"error": "Message body is required"}, nil
+	}
+	if len(body) > maxChatMessageLength {
+		return gin.H{"error": fmt.Sprintf("Message must be at most %d characters", maxChatMessageLength)}, nil
+	}
+	if denied, err := checkChatSender(db, user.UID, forumID); denied != nil || err != nil {
+		return denied, err
+	}
+
+	message := &Models.ForumChatMessages{
+		ForumID:   forumID,
+		UserID:    user.UID,
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
+	var wait time.Duration
+	err := db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		// The lock serializes a user's sends to one forum across instances,
+		// so the count below cannot be raced.
+		_, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "chat:"+forumID.String()+":"+user.UID.String())
+		if err != nil {
+			return err
+		}
+		var recent int
+		var oldest time.Time
+		err = tx.Model((*Models.ForumChatMessages)(nil)).
+			ColumnExpr("COUNT(*)").
+			ColumnExpr("COALESCE(MIN(created_at), ?)", message.CreatedAt).
+			Where("forum_id = ?", forumID).
+			Where("user_id = ?", user.UID).
+			Where("created_at > ?", message.CreatedAt.Add(-chatRateWindow)).
+			Select(&recent, &oldest)
+		if err != nil {
+			return err
+		}
+		if recent >= chatRateLimit {
+			wait = oldest.Add(chatRateWindow).Sub(message.CreatedAt)
+			return errChatRateLimited
+		}
+		_, err = tx.Model(message).Insert()
+		return err
+	})
+	if err == errChatRateLimited {
+		return gin.H{
+			"error":       "You are sending messages too fast",
+			"retry_after": int(math.Ceil(wait.Seconds())),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	message.User = user
+	hub.publish(chatTopic(forumID), "chat.message", message)
+	return nil, nil
+}
+
+func deleteChatMessage(db *pg.DB, forumID uuid.UUID, actorID uuid.UUID, messageID int, reason string) error {
+	var message Models.ForumChatMessages
+	err := db.Model(&message).
+		Where("id = ?", messageID).
+		Where("forum_id = ?", forumID).
+		Where("deleted_at IS NULL").
+		Select()
+	if err == pg.ErrNoRows {
+		return errChatMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	byModerator := message.UserID != actorID
+	if byModerator {
+		hasAccess, err := canModerateForum(db, actorID, forumID)
+		if err != nil {
+			return err
+		}
+		if !hasAccess {
+			return errChatDeleteForbidden
+		}
+	}
+
+	now := time.Now()
+	_, err = db.Model((*Models.ForumChatMessages)(nil)).
+		Set("body = ''").
+		Set("deleted_at = ?", now).
+		Set("deleted_by = ?", actorID).
+		Where("id = ?", messageID).
+		Update()
+	if err != nil {
+		return err
+	}
+
+	if byModerator {
+		recordAuditLog(db, &Models.AuditLogs{
+			ActorID:    actorID,
+			ForumID:    &forumID,
+			Action:     "chat.delete",
+			TargetType: "chat_message",
+			TargetID:   strconv.Itoa(messageID),
+			Before:     message,
+			Reason:     reason,
+		})
+		notifyModerationAction(db, actorID, message.UserID, forumID, nil, nil, "chat.delete", reason)
+	}
+	hub.publish(chatTopic(forumID), "chat.deleted", gin.H{"message_id": messageID, "deleted_by": actorID})
+	return nil
+}
+
+func GetForumChatMessages(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasAccess, err := canReadForumChat(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum membership"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only forum members can read the chat"})
+		return
+	}
+
+	limit := messagePageLimit(c)
+	before := 0
+	if cursor := c.Query("cursor"); cursor != "" {
+		if before, err = strconv.Atoi(cursor); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+	messages, err := loadChatMessages(db, forumID, before, 0, limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve chat messages", "detail": err.Error()})
+		return
+	}
+
+	nextCursor := ""
+	if len(messages) > limit {
+		messages = messages[:limit]
+		nextCursor = strconv.Itoa(messages[limit-1].ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"messages": messages, "next_cursor": nextCursor})
+}
+
+func DeleteForumChatMessage(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+	messageID, err := strconv.Atoi(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Message ID format"})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = deleteChatMessage(db, forumID, userID, messageID, strings.TrimSpace(c.Query("reason")))
+	if err == errChatMessageNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	if err == errChatDeleteForbidden {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "detail": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message", "detail": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Message deleted"})
+}
+
+type chatCommand struct {
+	Action    string `json:"action"`
+	Body      string `json:"body"`
+	MessageID int    `json:"message_id"`
+	Reason    string `json:"reason"`
+}
+
+func ServeForumChat(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasAccess, err := canReadForumChat(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum membership"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only forum members can join the chat"})
+		return
+	}
+	ban, err := getActiveForumBan(db, forumID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum access"})
+		return
+	}
+	if ban != nil && ban.Type == "ban" {
+		c.JSON(http.StatusForbidden, forumBanResponse(ban))
+		return
+	}
+	after := 0
+	if raw := c.Query("after"); raw != "" {
+		if after, err = strconv.Atoi(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after ID"})
+			return
+		}
+	}
+	var user Models.Users
+	if err := db.Model(&user).Column("uid", "username").Where("uid = ?", userID).Select(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+
+	conn, err := realtimeUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	s := newRealtimeSubscriber(userID)
+	hub.subscribe(s, chatTopic(forumID))
+	defer hub.unsubscribe(s, chatTopic(forumID))
+
+	var history []Models.ForumChatMessages
+	if after > 0 {
+		history, err = loadChatMessages(db, forumID, 0, after, maxChatBackfill)
+	} else {
+		history, err = loadChatMessages(db, forumID, 0, 0, chatBackfillSize)
+		for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+			history[i], history[j] = history[j], history[i]
+		}
+	}
+	if err != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "failed to load history"))
+		return
+	}
+	conn.SetWriteDeadline(time.Now().Add(realtimeWriteTimeout))
+	if err := conn.WriteJSON(gin.H{"type": "history", "topic": chatTopic(forumID), "messages": history}); err != nil {
+		return
+	}
+
+	replies := make(chan interface{}, realtimeBufferSize)
+	reply := func(v interface{}) {
+		select {
+		case replies <- v:
+		default:
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(4096)
+		conn.SetReadDeadline(time.Now().Add(2 * realtimeHeartbeat))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * realtimeHeartbeat))
+		})
+
+		var lastTyping time.Time
+		for {
+			var cmd chatCommand
+			if err := conn.ReadJSON(&cmd); err != nil {
+				return
+			}
+			switch cmd.Action {
+			case "send":
+				denied, err := sendChatMessage(db, forumID, &user, cmd.Body)
+				if err != nil {
+					reply(gin.H{"type": "error", "error": "Failed to send message"})
+				} else if denied != nil {
+					denied["type"] = "error"
+					reply(denied)
+				}
+			case "typing":
+				if time.Since(lastTyping) < chatTypingInterval {
+					continue
+				}
+				lastTyping = time.Now()
+				hub.publish(chatTopic(forumID), "chat.typing", gin.H{"user_id": user.UID, "username": user.Username})
+			case "delete":
+				err := deleteChatMessage(db, forumID, userID, cmd.MessageID, strings.TrimSpace(cmd.Reason))
+				if err == errChatMessageNotFound || err == errChatDeleteForbidden {
+					reply(gin.H{"type": "error", "error": err.Error()})
+				} else if err != nil {
+					reply(gin.H{"type": "error", "error": "Failed to delete message"})
+				}
+			default:
+				reply(gin.H{"type": "error", "error": "Action must be 'send', 'typing' or 'delete'"})
+			}
+		}
+	}()
+
+	writeRealtimeEvents(conn, s, replies, done)
+}
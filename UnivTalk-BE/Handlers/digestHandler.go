@@ -0,0 +1,492 @@
+package Handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Mailer"
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	digestBatchSize        = 100
+	digestTopPosts         = 5
+	digestNotificationList = 10
+	digestSendTimeout      = 30 * time.Second
+
+	defaultDigestFrequency = "off"
+	defaultDigestLanguage  = "id"
+)
+
+//go:embed templates/digest_*
+var digestTemplateFS embed.FS
+
+var (
+	digestPeriods = map[string]time.Duration{
+		"daily":  24 * time.Hour,
+		"weekly": 7 * 24 * time.Hour,
+	}
+	digestSubjects = map[string]map[string]string{
+		"id": {"daily": "Ringkasan harian UnivTalk kamu", "weekly": "Ringkasan mingguan UnivTalk kamu"},
+		"en": {"daily": "Your daily UnivTalk digest", "weekly": "Your weekly UnivTalk digest"},
+	}
+	digestHTML = map[string]*htmltemplate.Template{
+		"id": htmltemplate.Must(htmltemplate.ParseFS(digestTemplateFS, "templates/digest_id.html")),
+		"en": htmltemplate.Must(htmltemplate.ParseFS(digestTemplateFS, "templates/digest_en.html")),
+	}
+	digestText = map[string]*texttemplate.Template{
+		"id": texttemplate.Must(texttemplate.ParseFS(digestTemplateFS, "templates/digest_id.txt")),
+		"en": texttemplate.Must(texttemplate.ParseFS(digestTemplateFS, "templates/digest_en.txt")),
+	}
+
+	mailer Mailer.Mailer
+
+	errDigestSecretMissing = fmt.Errorf("DIGEST_UNSUBSCRIBE_SECRET is not set")
+)
+
+func SetMailer(m Mailer.Mailer) {
+	mailer = m
+}
+
+type digestPost struct {
+	ID         int
+	Title      string
+	ForumTitle string
+	Score      int
+	Comments   int
+	URL        string `pg:"-"`
+}
+
+type digestNotification struct {
+	Type      string
+	Actor     string
+	Milestone interface{}
+	Status    interface{}
+}
+
+type digestContent struct {
+	FirstName        string
+	Frequency        string
+	Posts            []digestPost
+	UnreadCount      int
+	Notifications    []digestNotification
+	NotificationsURL string
+	SettingsURL      string
+	UnsubscribeURL   string
+}
+
+func appURL() string {
+	if u := os.Getenv("APP_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	origin, _, _ := strings.Cut(os.Getenv("CLIENT_ADDR"), ",")
+	return strings.TrimRight(strings.TrimSpace(origin), "/")
+}
+
+func apiURL() string {
+	if u := os.Getenv("API_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// digestUnsubscribeSecret has no fallback: reusing the session secret would
+// let a leaked unsubscribe link help forge access tokens, and an empty key
+// would make every link forgeable.
+func digestUnsubscribeSecret() []byte {
+	return []byte(os.Getenv("DIGEST_UNSUBSCRIBE_SECRET"))
+}
+
+func digestUnsubscribeToken(userID uuid.UUID) string {
+	mac := hmac.New(sha256.New, digestUnsubscribeSecret())
+	mac.Write([]byte("digest-unsubscribe\n" + userID.String()))
+	return userID.String() + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func parseDigestUnsubscribeToken(token string) (uuid.UUID, bool) {
+	if len(digestUnsubscribeSecret()) == 0 {
+		return uuid.Nil, false
+	}
+	id, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, false
+	}
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return userID, hmac.Equal([]byte(token), []byte(digestUnsubscribeToken(userID)))
+}
+
+func getDigestSettings(db *pg.DB, userID uuid.UUID) (Models.EmailDigestSettings, error) {
+	settings := Models.EmailDigestSettings{UserID: userID}
+	err := db.Model(&settings).WherePK().Select()
+	if err == pg.ErrNoRows {
+		settings.Frequency, settings.Language = defaultDigestFrequency, defaultDigestLanguage
+		return settings, nil
+	}
+	return settings, err
+}
+
+func saveDigestSettings(db *pg.DB, settings *Models.EmailDigestSettings) error {
+	settings.UpdatedAt = time.Now()
+	_, err := db.Model(settings).
+		OnConflict("(user_id) DO UPDATE").
+		Set("frequency = EXCLUDED.frequency").
+		Set("language = EXCLUDED.language").
+		Set("last_sent_at = EXCLUDED.last_sent_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Insert()
+	return err
+}
+
+func buildDigest(db *pg.DB, user *Models.Users, settings *Models.EmailDigestSettings, now time.Time) (*Mailer.Message, error) {
+	since := now.Add(-digestPeriods[settings.Frequency])
+	if settings.LastSentAt != nil && settings.LastSentAt.After(since) {
+		since = *settings.LastSentAt
+	}
+
+	content := digestContent{
+		FirstName:        user.FirstName,
+		Frequency:        settings.Frequency,
+		Posts:            make([]digestPost, 0),
+		Notifications:    make([]digestNotification, 0),
+		NotificationsURL: appURL() + "/profile",
+		SettingsURL:      appURL() + "/profile",
+		UnsubscribeURL:   apiURL() + "/unsubscribe?token=" + url.QueryEscape(digestUnsubscribeToken(user.UID)),
+	}
+
+	_, err := db.Query(&content.Posts, `
+		SELECT p.id, p.title, f.title AS forum_title,
+		       COALESCE((SELECT SUM(v.value) FROM votes v WHERE v.post_id = p.id), 0) AS score,
+		       (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) AS comments
+		FROM posts p
+		JOIN forum_members m ON m.forum_id = p.forum_id AND m.user_id = ?0
+		JOIN forums f ON f.fid = p.forum_id
+		WHERE p.deleted_at IS NULL
+		  AND p.created_at >= ?1
+		  AND p.user_id <> ?0
+		  AND p.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?0)
+		ORDER BY score DESC, p.created_at DESC
+		LIMIT ?2
+	`, user.UID, since, digestTopPosts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range content.Posts {
+		content.Posts[i].URL = appURL() + "/posts/" + strconv.Itoa(content.Posts[i].ID)
+	}
+
+	unread := db.Model((*Models.Notifications)(nil)).
+		Where("user_id = ?", user.UID).
+		Where("read_at IS NULL")
+	if content.UnreadCount, err = unread.Count(); err != nil {
+		return nil, err
+	}
+	if content.UnreadCount > 0 {
+		var notifications []Models.Notifications
+		err := db.Model(&notifications).
+			Relation("Actor.username").
+			Where("notifications.user_id = ?", user.UID).
+			Where("notifications.read_at IS NULL").
+			Order("notifications.created_at DESC").
+			Limit(digestNotificationList).
+			Select()
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range notifications {
+			item := digestNotification{Type: n.Type}
+			if n.Actor != nil {
+				item.Actor = "@" + n.Actor.Username
+			}
+			if data, ok := n.Data.(map[string]interface{}); ok {
+				item.Milestone, item.Status = data["milestone"], data["status"]
+			}
+			content.Notifications = append(content.Notifications, item)
+		}
+	}
+
+	if len(content.Posts) == 0 && content.UnreadCount == 0 {
+		return nil, nil
+	}
+
+	lang := settings.Language
+	if digestHTML[lang] == nil {
+		lang = defaultDigestLanguage
+	}
+	var html, text bytes.Buffer
+	if err := digestHTML[lang].Execute(&html, content); err != nil {
+		return nil, err
+	}
+	if err := digestText[lang].Execute(&text, content); err != nil {
+		return nil, err
+	}
+	return &Mailer.Message{
+		To:      user.Email,
+		Subject: digestSubjects[lang][settings.Frequency],
+		Text:    text.String(),
+		HTML:    html.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + content.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}, nil
+}
+
+func sendDigest(db *pg.DB, user *Models.Users, settings *Models.EmailDigestSettings, now time.Time) (bool, error) {
+	if len(digestUnsubscribeSecret()) == 0 {
+		return false, errDigestSecretMissing
+	}
+	msg, err := buildDigest(db, user, settings, now)
+	if err != nil {
+		return false, err
+	}
+	if msg != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), digestSendTimeout)
+		err := mailer.Send(ctx, *msg)
+		cancel()
+		if err != nil {
+			return false, err
+		}
+	}
+	settings.LastSentAt = &now
+	return msg != nil, saveDigestSettings(db, settings)
+}
+
+func sendDueDigests(db *pg.DB, now time.Time) (int, error) {
+	if len(digestUnsubscribeSecret()) == 0 {
+		return 0, errDigestSecretMissing
+	}
+	sent := 0
+	lastUID := uuid.Nil
+	for {
+		var users []Models.Users
+		err := db.Model(&users).
+			ColumnExpr("users.*").
+			Join("LEFT JOIN email_digest_settings AS s ON s.user_id = users.uid").
+			Where("users.status = ?", "active").
+			Where("users.uid > ?", lastUID).
+			Where(`(COALESCE(s.frequency, ?0) = 'daily' AND (s.last_sent_at IS NULL OR s.last_sent_at <= ?1))
+				OR (COALESCE(s.frequency, ?0) = 'weekly' AND (s.last_sent_at IS NULL OR s.last_sent_at <= ?2))`,
+				defaultDigestFrequency, now.Add(-digestPeriods["daily"]), now.Add(-digestPeriods["weekly"])).
+			Order("users.uid ASC").
+			Limit(digestBatchSize).
+			Select()
+		if err != nil {
+			return sent, err
+		}
+		if len(users) == 0 {
+			return sent, nil
+		}
+		for i := range users {
+			lastUID = users[i].UID
+			settings, err := getDigestSettings(db, users[i].UID)
+			if err != nil {
+				return sent, err
+			}
+			ok, err := sendDigest(db, &users[i], &settings, now)
+			if err != nil {
+				log.Printf("Send Digest Failed (%s): %v", users[i].UID, err)
+				continue
+			}
+			if ok {
+				sent++
+			}
+		}
+	}
+}
+
+func StartDigestJob(db *pg.DB, interval time.Duration) {
+	if mailer == nil {
+		return
+	}
+	if len(digestUnsubscribeSecret()) == 0 {
+		log.Printf("Digest Job Disabled: %v", errDigestSecretMissing)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		sent, err := sendDueDigests(db, time.Now())
+		if err != nil {
+			log.Printf("Digest Job Failed: %v", err)
+		}
+		if sent > 0 {
+			log.Printf("Digest Job: sent %d digests", sent)
+		}
+	}
+}
+
+func RunSendDigestCommand(db *pg.DB, args []string) int {
+	flags := flag.NewFlagSet("send-digest", flag.ContinueOnError)
+	username := flags.String("user", "", "send the digest to this username now, even if it is not due")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if mailer == nil {
+		fmt.Fprintln(os.Stderr, "Email is not configured:", Mailer.ErrNotConfigured)
+		return 1
+	}
+
+	if *username == "" {
+		sent, err := sendDueDigests(db, time.Now())
+		fmt.Printf("Sent %d digests\n", sent)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Send digests failed:", err)
+			return 1
+		}
+		return 0
+	}
+
+	var user Models.Users
+	if err := db.Model(&user).Where("username = ?", *username).Select(); err != nil {
+		fmt.Fprintln(os.Stderr, "User not found:", err)
+		return 1
+	}
+	settings, err := getDigestSettings(db, user.UID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to retrieve digest settings:", err)
+		return 1
+	}
+	if settings.Frequency == "off" {
+		settings.Frequency = "weekly"
+	}
+	ok, err := sendDigest(db, &user, &settings, time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Send digest failed:", err)
+		return 1
+	}
+	if !ok {
+		fmt.Println("Nothing to send")
+		return 0
+	}
+	fmt.Println("Digest sent to", user.Email)
+	return 0
+}
+
+func GetDigestSettings(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := getDigestSettings(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve digest settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"digest": settings})
+}
+
+func UpdateDigestSettings(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var payload struct {
+		Frequency string `json:"frequency"`
+		Language  string `json:"language"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+
+	settings, err := getDigestSettings(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve digest settings"})
+		return
+	}
+	if payload.Frequency != "" {
+		if _, ok := digestPeriods[payload.Frequency]; !ok && payload.Frequency != "off" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Frequency must be 'daily', 'weekly' or 'off'"})
+			return
+		}
+		settings.Frequency = payload.Frequency
+	}
+	if payload.Language != "" {
+		if digestHTML[payload.Language] == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Language must be 'id' or 'en'"})
+			return
+		}
+		settings.Language = payload.Language
+	}
+
+	if err := saveDigestSettings(db, &settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update digest settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Digest settings updated", "digest": settings})
+}
+
+var unsubscribePage = htmltemplate.Must(htmltemplate.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: Arial, sans-serif; max-width: 480px; margin: 40px auto; text-align: center;">
+  {{if .Done}}
+  <p>Kamu tidak akan menerima ringkasan email lagi.<br>You will no longer receive email digests.</p>
+  {{else}}
+  <form method="POST">
+    <p>Berhenti menerima ringkasan email UnivTalk?<br>Stop receiving UnivTalk email digests?</p>
+    <button type="submit">Berhenti berlangganan / Unsubscribe</button>
+  </form>
+  {{end}}
+</body>
+</html>`))
+
+func renderUnsubscribePage(c *gin.Context, status int, done bool) {
+	var buf bytes.Buffer
+	unsubscribePage.Execute(&buf, gin.H{"Done": done})
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+func ConfirmUnsubscribe(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	if _, ok := parseDigestUnsubscribeToken(c.Query("token")); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unsubscribe link"})
+		return
+	}
+	renderUnsubscribePage(c, http.StatusOK, false)
+}
+
+func Unsubscribe(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, ok := parseDigestUnsubscribeToken(c.Query("token"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unsubscribe link"})
+		return
+	}
+
+	settings, err := getDigestSettings(db, userID)
+	if err == nil {
+		settings.Frequency = "off"
+		err = saveDigestSettings(db, &settings)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe"})
+		return
+	}
+	renderUnsubscribePage(c, http.StatusOK, true)
+}
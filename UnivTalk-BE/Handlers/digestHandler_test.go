@@ -0,0 +1,57 @@
+package Handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseDigestUnsubscribeToken(t *testing.T) {
+	t.Setenv("DIGEST_UNSUBSCRIBE_SECRET", "digest-test-secret")
+
+	userID := uuid.New()
+	valid := digestUnsubscribeToken(userID)
+	id, signature, _ := strings.Cut(valid, ".")
+
+	t.Setenv("DIGEST_UNSUBSCRIBE_SECRET", "other-secret")
+	otherSecret := digestUnsubscribeToken(userID)
+	t.Setenv("DIGEST_UNSUBSCRIBE_SECRET", "digest-test-secret")
+
+	tests := []struct {
+		name   string
+		token  string
+		wantOK bool
+	}{
+		{"valid token", valid, true},
+		{"signature for another user", uuid.New().String() + "." + signature, false},
+		{"tampered signature", id + "." + strings.Repeat("0", len(signature)), false},
+		{"signed with another secret", otherSecret, false},
+		{"missing signature", id, false},
+		{"empty signature", id + ".", false},
+		{"invalid user ID", "not-a-uuid." + signature, false},
+		{"empty token", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseDigestUnsubscribeToken(tt.token)
+			if ok != tt.wantOK {
+				t.Fatalf("parseDigestUnsubscribeToken(%q) ok = %v, want %v", tt.token, ok, tt.wantOK)
+			}
+			if ok && got != userID {
+				t.Errorf("user ID = %s, want %s", got, userID)
+			}
+		})
+	}
+
+	t.Run("secret not set", func(t *testing.T) {
+		t.Setenv("DIGEST_UNSUBSCRIBE_SECRET", "")
+		unsigned := digestUnsubscribeToken(userID)
+		if _, ok := parseDigestUnsubscribeToken(unsigned); ok {
+			t.Fatal("token accepted without DIGEST_UNSUBSCRIBE_SECRET")
+		}
+		if _, ok := parseDigestUnsubscribeToken(valid); ok {
+			t.Fatal("token accepted without DIGEST_UNSUBSCRIBE_SECRET")
+		}
+	})
+}
@@ -0,0 +1,491 @@
+package Handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	maxForumFlairs = 30
+	maxFlairName   = 32
+)
+
+var (
+	flairColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+	errFlairNotFound = fmt.Errorf("flair not found in this forum")
+	errFlairModOnly  = fmt.Errorf("only moderators can use this flair")
+	errFlairLocked   = fmt.Errorf("the current flair was set by a moderator and can only be changed by one")
+)
+
+func loadFlairs(db *pg.DB, flairIDs []int) (map[int]*Models.ForumFlairs, error) {
+	result := make(map[int]*Models.ForumFlairs)
+	if len(flairIDs) == 0 {
+		return result, nil
+	}
+	var flairs []Models.ForumFlairs
+	if err := db.Model(&flairs).Where("id IN (?)", pg.In(flairIDs)).Select(); err != nil {
+		return nil, err
+	}
+	for i := range flairs {
+		result[flairs[i].ID] = &flairs[i]
+	}
+	return result, nil
+}
+
+func attachFlairs(db *pg.DB, posts []Models.PostWithCounts) {
+	flairIDs := make([]int, 0)
+	for _, p := range posts {
+		if p.FlairID != nil {
+			flairIDs = append(flairIDs, *p.FlairID)
+		}
+	}
+	flairs, err := loadFlairs(db, flairIDs)
+	if err != nil {
+		return
+	}
+	for i := range posts {
+		if posts[i].FlairID != nil {
+			posts[i].Flair = flairs[*posts[i].FlairID]
+		}
+	}
+}
+
+func parseFlairID(raw string) (*int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "0" || raw == "null" {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id < 0 {
+		return nil, fmt.Errorf("invalid flair_id %q", raw)
+	}
+	return &id, nil
+}
+
+func resolvePostFlair(db *pg.DB, forumID uuid.UUID, userID uuid.UUID, currentID *int, newID *int) (*Models.ForumFlairs, error) {
+	if currentID != nil && newID != nil && *currentID == *newID {
+		flairs, err := loadFlairs(db, []int{*currentID})
+		if err != nil {
+			return nil, err
+		}
+		return flairs[*currentID], nil
+	}
+
+	requireModerator := func(lockedErr error) error {
+		allowed, err := canModerateForum(db, userID, forumID)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return lockedErr
+		}
+		return nil
+	}
+
+	if currentID != nil {
+		flairs, err := loadFlairs(db, []int{*currentID})
+		if err != nil {
+			return nil, err
+		}
+		if current := flairs[*currentID]; current != nil && current.ModOnly {
+			if err := requireModerator(errFlairLocked); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if newID == nil {
+		return nil, nil
+	}
+
+	flair := &Models.ForumFlairs{}
+	err := db.Model(flair).
+		Where("id = ?", *newID).
+		Where("forum_id = ?", forumID).
+		Select()
+	if err == pg.ErrNoRows {
+		return nil, errFlairNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if flair.ModOnly {
+		if err := requireModerator(errFlairModOnly); err != nil {
+			return nil, err
+		}
+	}
+	return flair, nil
+}
+
+func flairErrorResponse(c *gin.Context, err error) {
+	switch err {
+	case errFlairNotFound:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flair", "detail": err.Error()})
+	case errFlairModOnly, errFlairLocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "detail": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify flair"})
+	}
+}
+
+func requireFlairManager(c *gin.Context, db *pg.DB) (uuid.UUID, uuid.UUID, bool) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to manage this forum's flairs",
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return forumID, userID, true
+}
+
+func getForumFlair(c *gin.Context, db *pg.DB, forumID uuid.UUID) (*Models.ForumFlairs, bool) {
+	flairID, err := strconv.Atoi(c.Param("flair_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Flair ID format"})
+		return nil, false
+	}
+
+	flair := &Models.ForumFlairs{}
+	err = db.Model(flair).
+		Where("id = ?", flairID).
+		Where("forum_id = ?", forumID).
+		Select()
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flair not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve flair"})
+		return nil, false
+	}
+	return flair, true
+}
+
+func validateFlair(db *pg.DB, flair *Models.ForumFlairs) (int, gin.H) {
+	flair.Name = strings.TrimSpace(flair.Name)
+	if flair.Name == "" {
+		return http.StatusBadRequest, gin.H{"error": "Name is required"}
+	}
+	if len(flair.Name) > maxFlairName {
+		return http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Name must be at most %d characters", maxFlairName)}
+	}
+	if !flairColorPattern.MatchString(flair.Color) {
+		return http.StatusBadRequest, gin.H{"error": "Color must be a hex color like #1E88E5"}
+	}
+	flair.Color = strings.ToUpper(flair.Color)
+
+	taken, err := db.Model((*Models.ForumFlairs)(nil)).
+		Where("forum_id = ?", flair.ForumID).
+		Where("LOWER(name) = LOWER(?)", flair.Name).
+		Where("id <> ?", flair.ID).
+		Exists()
+	if err != nil {
+		return http.StatusInternalServerError, gin.H{"error": "Failed to retrieve flairs"}
+	}
+	if taken {
+		return http.StatusConflict, gin.H{"error": "A flair with this name already exists"}
+	}
+	return 0, nil
+}
+
+func GetForumFlairs(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	allowed, err := canViewForum(db, userID, forumID)
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forum not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum access"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This forum is private"})
+		return
+	}
+
+	flairs := make([]Models.ForumFlairs, 0)
+	err = db.Model(&flairs).
+		Where("forum_id = ?", forumID).
+		Order("id ASC").
+		Select()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve flairs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"flairs": flairs})
+}
+
+func CreateForumFlair(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, userID, ok := requireFlairManager(c, db)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Name    string `json:"name" binding:"required"`
+		Color   string `json:"color" binding:"required"`
+		ModOnly bool   `json:"mod_only"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+
+	count, err := db.Model((*Models.ForumFlairs)(nil)).Where("forum_id = ?", forumID).Count()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve flairs"})
+		return
+	}
+	if count >= maxForumFlairs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A forum can have at most %d flairs", maxForumFlairs)})
+		return
+	}
+
+	now := time.Now()
+	flair := &Models.ForumFlairs{
+		ForumID:   forumID,
+		Name:      payload.Name,
+		Color:     payload.Color,
+		ModOnly:   payload.ModOnly,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if status, body := validateFlair(db, flair); status != 0 {
+		c.JSON(status, body)
+		return
+	}
+	if _, err := db.Model(flair).Returning("*").Insert(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create flair", "detail": err.Error()})
+		return
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "flair.create",
+		TargetType: "flair",
+		TargetID:   strconv.Itoa(flair.ID),
+		After:      flair,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Flair created", "flair": flair})
+}
+
+func UpdateForumFlair(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, userID, ok := requireFlairManager(c, db)
+	if !ok {
+		return
+	}
+	flair, ok := getForumFlair(c, db, forumID)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Name    *string `json:"name"`
+		Color   *string `json:"color"`
+		ModOnly *bool   `json:"mod_only"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+
+	before := *flair
+	if payload.Name != nil {
+		flair.Name = *payload.Name
+	}
+	if payload.Color != nil {
+		flair.Color = *payload.Color
+	}
+	if payload.ModOnly != nil {
+		flair.ModOnly = *payload.ModOnly
+	}
+	if status, body := validateFlair(db, flair); status != 0 {
+		c.JSON(status, body)
+		return
+	}
+
+	flair.UpdatedAt = time.Now()
+	_, err := db.Model(flair).
+		Column("name", "color", "mod_only", "updated_at").
+		WherePK().
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update flair"})
+		return
+	}
+
+	ch.Delete(fmt.Sprintf("posts_forum_%s", forumID.String()))
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "flair.update",
+		TargetType: "flair",
+		TargetID:   strconv.Itoa(flair.ID),
+		Before:     before,
+		After:      flair,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Flair updated", "flair": flair})
+}
+
+func DeleteForumFlair(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, userID, ok := requireFlairManager(c, db)
+	if !ok {
+		return
+	}
+	flair, ok := getForumFlair(c, db, forumID)
+	if !ok {
+		return
+	}
+
+	if _, err := db.Model(flair).WherePK().Delete(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete flair"})
+		return
+	}
+
+	ch.Delete(fmt.Sprintf("posts_forum_%s", forumID.String()))
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "flair.delete",
+		TargetType: "flair",
+		TargetID:   strconv.Itoa(flair.ID),
+		Before:     flair,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Flair deleted"})
+}
+
+func SetPostFlair(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	postID, err := strconv.Atoi(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID format"})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var post Models.Posts
+	if err := db.Model(&post).Where("id = ?", postID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	isModerator, err := canModerateForum(db, userID, post.ForumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if post.UserID != userID && !isModerator {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "Only the author or a moderator can change this post's flair",
+		})
+		return
+	}
+	if !isModerator {
+		ban, err := getActiveForumBan(db, post.ForumID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum access"})
+			return
+		}
+		if ban != nil {
+			c.JSON(http.StatusForbidden, forumBanResponse(ban))
+			return
+		}
+		if rejectLockedPost(c, &post, "The flair of a locked post cannot be changed") {
+			return
+		}
+	}
+
+	var payload struct {
+		FlairID *int `json:"flair_id"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+	if payload.FlairID != nil && *payload.FlairID == 0 {
+		payload.FlairID = nil
+	}
+
+	flair, err := resolvePostFlair(db, post.ForumID, userID, post.FlairID, payload.FlairID)
+	if err != nil {
+		flairErrorResponse(c, err)
+		return
+	}
+
+	_, err = db.Model((*Models.Posts)(nil)).
+		Set("flair_id = ?", payload.FlairID).
+		Where("id = ?", post.ID).
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update flair"})
+		return
+	}
+
+	ch.Delete(fmt.Sprintf("posts_forum_%s", post.ForumID.String()))
+	ch.Delete(fmt.Sprintf("post_%d", post.ID))
+
+	if post.UserID != userID {
+		recordAuditLog(db, &Models.AuditLogs{
+			ActorID:    userID,
+			ForumID:    &post.ForumID,
+			Action:     "post.flair",
+			TargetType: "post",
+			TargetID:   strconv.Itoa(post.ID),
+			Before:     gin.H{"flair_id": post.FlairID},
+			After:      gin.H{"flair_id": payload.FlairID},
+			Reason:     c.Query("reason"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Post flair updated", "flair_id": payload.FlairID, "flair": flair})
+}
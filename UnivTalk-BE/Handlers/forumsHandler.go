@@ -40,6 +40,23 @@ func isSystemAdmin(db *pg.DB, userID uuid.UUID) (bool, error) {
 	return user.IsAdmin, nil
 }
 
+func isForumAdmin(db *pg.DB, userID uuid.UUID, forumID uuid.UUID) bool {
+	var forumMember Models.ForumMembers
+	err := db.Model(&forumMember).
+		Where("user_id = ?", userID).
+		Where("forum_id = ?", forumID).
+		Select()
+	return err == nil && forumMember.Role == "admin"
+}
+
+func canModerateForum(db *pg.DB, userID uuid.UUID, forumID uuid.UUID) (bool, error) {
+	isSysAdmin, err := isSystemAdmin(db, userID)
+	if err != nil {
+		return false, err
+	}
+	return isSysAdmin || isForumAdmin(db, userID, forumID), nil
+}
+
 func GetCategories(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 	cacheKey := "categories_all"
 
@@ -93,6 +110,7 @@ func CreateForum(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		Title:       reqBody.Title,
 		Description: reqBody.Description,
 		CategoryID:  reqBody.CategoryID,
+		IsPrivate:   reqBody.IsPrivate,
 	}
 
 	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
@@ -162,27 +180,30 @@ func GetForumByID(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 
 	cacheKey := fmt.Sprintf("forum_%s", forumIDStr)
 
-	if saved, found := ch.Get(cacheKey); found {
-		c.JSON(http.StatusOK, gin.H{
-			"forum": saved,
-		})
-		return
+	forum, found := ch.Get(cacheKey)
+	if !found {
+		var loaded Models.Forums
+		err = db.Model(&loaded).Where("fid = ?", forumID).Select()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":  "Failed to retrieve forum",
+				"detail": err.Error(),
+			})
+			return
+		}
+		ch.Set(cacheKey, loaded, 30*time.Minute)
+		forum = loaded
 	}
 
-	var forum Models.Forums
-	err = db.Model(&forum).Where("fid = ?", forumID).Select()
+	storage, err := forumStorageUsage(db, forumID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":  "Failed to retrieve forum",
-			"detail": err.Error(),
-		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve storage usage"})
 		return
 	}
 
-	ch.Set(cacheKey, forum, 30*time.Minute)
-
 	c.JSON(http.StatusOK, gin.H{
-		"forum": forum,
+		"forum":   forum,
+		"storage": storage,
 	})
 }
 
@@ -242,6 +263,15 @@ func UpdateForum(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
+	var before Models.Forums
+	if err := db.Model(&before).Where("fid = ?", forumID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Forum not found or already deleted",
+		})
+		return
+	}
+
+	reqBody.UpdatedAt = time.Now()
 	res, err := db.Model(&reqBody).
 		Column("title", "description", "category_id", "updated_at").
 		Where("fid = ?", forumID).
@@ -261,6 +291,21 @@ func UpdateForum(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
+	after := before
+	after.Title = reqBody.Title
+	after.Description = reqBody.Description
+	after.CategoryID = reqBody.CategoryID
+	after.UpdatedAt = reqBody.UpdatedAt
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "forum.update",
+		TargetType: "forum",
+		TargetID:   forumID.String(),
+		Before:     before,
+		After:      after,
+	})
+
 	ch.Delete("forums_all")
 	ch.Delete(fmt.Sprintf("forum_%s", forumIDStr))
 
@@ -311,8 +356,31 @@ func DeleteForum(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
-	forum := &Models.Forums{FID: forumID}
-	res, err := db.Model(forum).Where("fid = ?", forumID).Delete()
+	var forum Models.Forums
+	if err := db.Model(&forum).Where("fid = ?", forumID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Forum not found or already deleted",
+		})
+		return
+	}
+
+	var postIDs []int
+	err = db.Model((*Models.Posts)(nil)).
+		AllWithDeleted().
+		Column("id").
+		Where("forum_id = ?", forumID).
+		Select(&postIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve forum posts"})
+		return
+	}
+	mediaKeys, err := mediaKeysForPosts(db, postIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve forum media"})
+		return
+	}
+
+	res, err := db.Model(&forum).Where("fid = ?", forumID).Delete()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":  "Failed to delete forum",
@@ -328,6 +396,18 @@ func DeleteForum(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "forum.delete",
+		TargetType: "forum",
+		TargetID:   forumID.String(),
+		Before:     forum,
+		Reason:     c.Query("reason"),
+	})
+
+	go deleteStoredMedia(mediaKeys)
+
 	ch.Delete("forums_all")
 	ch.Delete(fmt.Sprintf("forum_%s", forumIDStr))
 
@@ -376,6 +456,30 @@ func JoinForum(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
+	ban, err := getActiveForumBan(db, forumID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum access"})
+		return
+	}
+	if ban != nil && ban.Type == "ban" {
+		c.JSON(http.StatusForbidden, forumBanResponse(ban))
+		return
+	}
+
+	privateForum, err := isPrivateForum(db, forumID)
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forum not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve forum"})
+		return
+	}
+	if isSysAdmin, _ := isSystemAdmin(db, userID); privateForum && !isSysAdmin {
+		requestToJoinForum(c, db, forumID, userID)
+		return
+	}
+
 	forumMember := &Models.ForumMembers{
 		UserID:  userID,
 		ForumID: forumID,
@@ -390,6 +494,7 @@ func JoinForum(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		})
 		return
 	}
+	enqueueMembershipWebhook(db, forumID, userID, webhookMemberJoined)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Joined forum successfully",
@@ -452,7 +557,7 @@ func LeaveForum(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		UserID:  userID,
 		ForumID: forumID,
 	}
-	_, err = db.Model(forumMember).Where("user_id = ? AND forum_id = ?", forumMember.UserID, forumMember.ForumID).Delete()
+	res, err := db.Model(forumMember).Where("user_id = ? AND forum_id = ?", forumMember.UserID, forumMember.ForumID).Delete()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":  "Failed to leave forum",
@@ -460,6 +565,10 @@ func LeaveForum(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		})
 		return
 	}
+	if res.RowsAffected() > 0 {
+		enqueueMembershipWebhook(db, forumID, userID, webhookMemberLeft)
+		revokeForumSubscriptions(db, forumID, userID)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Left forum successfully",
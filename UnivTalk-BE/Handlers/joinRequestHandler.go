@@ -0,0 +1,253 @@
+package Handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+func getPendingJoinRequest(db *pg.DB, forumID uuid.UUID, userID uuid.UUID) (*Models.ForumJoinRequests, error) {
+	var pending Models.ForumJoinRequests
+	err := db.Model(&pending).
+		Where("forum_id = ?", forumID).
+		Where("user_id = ?", userID).
+		Where("status = ?", "pending").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+func requestToJoinForum(c *gin.Context, db *pg.DB, forumID uuid.UUID, userID uuid.UUID) {
+	isMember, err := db.Model((*Models.ForumMembers)(nil)).
+		Where("forum_id = ?", forumID).
+		Where("user_id = ?", userID).
+		Exists()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum membership"})
+		return
+	}
+	if isMember {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already a member of this forum"})
+		return
+	}
+
+	pending, err := getPendingJoinRequest(db, forumID, userID)
+	if err == nil {
+		c.JSON(http.StatusAccepted, gin.H{"message": "Join request is pending approval", "join_request": pending})
+		return
+	}
+	if err != pg.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve join request"})
+		return
+	}
+
+	var payload struct {
+		Message string `json:"message"`
+	}
+	c.ShouldBindJSON(&payload)
+
+	request := &Models.ForumJoinRequests{
+		ForumID:   forumID,
+		UserID:    userID,
+		Message:   strings.TrimSpace(payload.Message),
+		Status:    "pending",
+		CreatedAt: time.Now(),
+	}
+	if _, err := db.Model(request).Insert(); err != nil {
+		// A concurrent request from the same user won the pending slot.
+		if pgErr, ok := err.(pg.Error); ok && pgErr.Field('C') == "23505" {
+			if pending, err := getPendingJoinRequest(db, forumID, userID); err == nil {
+				c.JSON(http.StatusAccepted, gin.H{"message": "Join request is pending approval", "join_request": pending})
+				return
+			}
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send join request", "detail": err.Error()})
+		return
+	}
+
+	var adminIDs []uuid.UUID
+	err = db.Model((*Models.ForumMembers)(nil)).
+		Column("user_id").
+		Where("forum_id = ?", forumID).
+		Where("role = ?", "admin").
+		Select(&adminIDs)
+	if err != nil {
+		log.Printf("Notify Join Request Failed (forum %s): %v", forumID, err)
+	}
+	for _, adminID := range adminIDs {
+		createNotification(db, &Models.Notifications{
+			UserID:  adminID,
+			ActorID: &userID,
+			Type:    notifyJoinRequest,
+			ForumID: &forumID,
+			Data:    gin.H{"request_id": request.ID},
+		})
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Join request sent", "join_request": request})
+}
+
+func GetForumJoinRequests(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to view this forum's join requests",
+		})
+		return
+	}
+
+	requests := make([]Models.ForumJoinRequests, 0)
+	query := db.Model(&requests).
+		Relation("User.uid").
+		Relation("User.username").
+		Where("forum_join_requests.forum_id = ?", forumID)
+	if status := c.DefaultQuery("status", "pending"); status != "all" {
+		query.Where("forum_join_requests.status = ?", status)
+	}
+	if err := query.Order("forum_join_requests.created_at ASC").Select(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve join requests", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"join_requests": requests})
+}
+
+func ApproveJoinRequest(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	decideJoinRequest(c, db, ch, true)
+}
+
+func RejectJoinRequest(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	decideJoinRequest(c, db, ch, false)
+}
+
+func decideJoinRequest(c *gin.Context, db *pg.DB, ch *cache.Cache, approve bool) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Request ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to review this forum's join requests",
+		})
+		return
+	}
+
+	var request Models.ForumJoinRequests
+	err = db.Model(&request).
+		Where("id = ?", requestID).
+		Where("forum_id = ?", forumID).
+		Select()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Join request not found"})
+		return
+	}
+	if request.Status != "pending" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Join request has already been decided"})
+		return
+	}
+
+	status, action := "rejected", "forum.join_reject"
+	if approve {
+		status, action = "approved", "forum.join_approve"
+	}
+	now := time.Now()
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		res, err := tx.Model(&request).
+			Set("status = ?", status).
+			Set("decided_by = ?", userID).
+			Set("decided_at = ?", now).
+			Where("id = ?", requestID).
+			Where("status = ?", "pending").
+			Update()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+		if !approve {
+			return nil
+		}
+		member := &Models.ForumMembers{UserID: request.UserID, ForumID: forumID, Role: "member"}
+		_, err = tx.Model(member).OnConflict("(user_id, forum_id) DO NOTHING").Insert()
+		return err
+	})
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "Join request has already been decided"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update join request", "detail": err.Error()})
+		return
+	}
+	request.Status, request.DecidedBy, request.DecidedAt = status, &userID, &now
+
+	reason := strings.TrimSpace(c.Query("reason"))
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   request.UserID.String(),
+		After:      request,
+		Reason:     reason,
+	})
+	if approve {
+		enqueueMembershipWebhook(db, forumID, request.UserID, webhookMemberJoined)
+	}
+	createNotification(db, &Models.Notifications{
+		UserID:  request.UserID,
+		ActorID: &userID,
+		Type:    notifyJoinRequestDecision,
+		ForumID: &forumID,
+		Data:    gin.H{"request_id": request.ID, "status": status, "reason": reason},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Join request " + status, "join_request": request})
+}
@@ -0,0 +1,265 @@
+package Handlers
+
+import (
+	"bytes"
+	"log"
+	"regexp"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/go-pg/pg/v10"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	markdownRenderer = goldmark.New(
+		goldmark.WithExtensions(
+			extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+			extension.Strikethrough,
+			extension.Linkify,
+			extension.TaskList,
+			mathExtension{},
+		),
+		goldmark.WithRendererOptions(html.WithHardWraps()),
+	)
+	markdownPolicy = newMarkdownPolicy()
+)
+
+func newMarkdownPolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^math math-(inline|display)$`)).OnElements("span", "div")
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+	policy.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+	return policy
+}
+
+func renderMarkdown(source string) string {
+	var buf bytes.Buffer
+	if err := markdownRenderer.Convert([]byte(source), &buf); err != nil {
+		log.Printf("Render Markdown Failed: %v", err)
+		return "<p>" + string(util.EscapeHTML([]byte(source))) + "</p>"
+	}
+	return markdownPolicy.Sanitize(buf.String())
+}
+
+const markdownBackfillBatch = 200
+
+func BackfillRenderedBodies(db *pg.DB) {
+	rendered, lastID := 0, 0
+	for {
+		var posts []Models.Posts
+		err := db.Model(&posts).
+			AllWithDeleted().
+			Column("id", "body").
+			Where("body_html = '' AND body <> ''").
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(markdownBackfillBatch).
+			Select()
+		if err != nil {
+			log.Printf("Markdown Backfill Failed (posts): %v", err)
+			return
+		}
+		if len(posts) == 0 {
+			break
+		}
+		for _, p := range posts {
+			lastID = p.ID
+			_, err := db.Model((*Models.Posts)(nil)).
+				AllWithDeleted().
+				Set("body_html = ?", renderMarkdown(p.Body)).
+				Where("id = ?", p.ID).
+				Update()
+			if err != nil {
+				log.Printf("Markdown Backfill Failed (post %d): %v", p.ID, err)
+				return
+			}
+			rendered++
+		}
+	}
+
+	lastID = 0
+	for {
+		var comments []Models.Comments
+		err := db.Model(&comments).
+			AllWithDeleted().
+			Column("id", "body").
+			Where("body_html = '' AND body <> ''").
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(markdownBackfillBatch).
+			Select()
+		if err != nil {
+			log.Printf("Markdown Backfill Failed (comments): %v", err)
+			return
+		}
+		if len(comments) == 0 {
+			break
+		}
+		for _, cmt := range comments {
+			lastID = cmt.ID
+			_, err := db.Model((*Models.Comments)(nil)).
+				AllWithDeleted().
+				Set("body_html = ?", renderMarkdown(cmt.Body)).
+				Where("id = ?", cmt.ID).
+				Update()
+			if err != nil {
+				log.Printf("Markdown Backfill Failed (comment %d): %v", cmt.ID, err)
+				return
+			}
+			rendered++
+		}
+	}
+
+	if rendered > 0 {
+		log.Printf("Markdown Backfill: rendered %d bodies", rendered)
+	}
+}
+
+var (
+	kindMathInline = ast.NewNodeKind("MathInline")
+	kindMathBlock  = ast.NewNodeKind("MathBlock")
+)
+
+type mathInline struct {
+	ast.BaseInline
+	Value []byte
+}
+
+func (n *mathInline) Kind() ast.NodeKind { return kindMathInline }
+
+func (n *mathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Value": string(n.Value)}, nil)
+}
+
+type mathBlock struct {
+	ast.BaseBlock
+	closed bool
+}
+
+func (n *mathBlock) Kind() ast.NodeKind { return kindMathBlock }
+
+func (n *mathBlock) IsRaw() bool { return true }
+
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte { return []byte{'$'} }
+
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if len(line) < 3 || line[1] == '$' || util.IsSpace(line[1]) {
+		return nil
+	}
+	for i := 2; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] == '$':
+			if util.IsSpace(line[i-1]) || (i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9') {
+				continue
+			}
+			node := &mathInline{Value: append([]byte(nil), line[1:i]...)}
+			block.Advance(i + 1)
+			return node
+		}
+	}
+	return nil
+}
+
+type mathBlockParser struct{}
+
+func (p *mathBlockParser) Trigger() []byte { return []byte{'$'} }
+
+func (p *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockIndent()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	node := &mathBlock{}
+	rest := line[pos+2:]
+	if inner := bytes.TrimSpace(rest); len(inner) > 0 {
+		if len(inner) < 3 || !bytes.HasSuffix(inner, []byte("$$")) {
+			return nil, parser.NoChildren
+		}
+		start := segment.Start - segment.Padding + pos + 2 + util.TrimLeftSpaceLength(rest)
+		node.Lines().Append(text.NewSegment(start, start+len(inner)-2))
+		node.closed = true
+	}
+	reader.AdvanceToEOL()
+	return node, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	if node.(*mathBlock).closed {
+		return parser.Close
+	}
+	line, segment := reader.PeekLine()
+	if bytes.Equal(bytes.TrimSpace(line), []byte("$$")) {
+		reader.AdvanceToEOL()
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	reader.AdvanceToEOL()
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *mathBlockParser) CanInterruptParagraph() bool { return true }
+
+func (p *mathBlockParser) CanAcceptIndentedLine() bool { return false }
+
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMathInline, r.renderInline)
+	reg.Register(kindMathBlock, r.renderBlock)
+}
+
+func (r *mathRenderer) renderInline(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.WriteString(`<span class="math math-inline">\(`)
+		w.Write(util.EscapeHTML(node.(*mathInline).Value))
+		w.WriteString(`\)</span>`)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathRenderer) renderBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.WriteString(`<div class="math math-display">\[`)
+		lines := node.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			segment := lines.At(i)
+			w.Write(util.EscapeHTML(segment.Value(source)))
+		}
+		w.WriteString("\\]</div>\n")
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+type mathExtension struct{}
+
+func (e mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 650)),
+		parser.WithInlineParsers(util.Prioritized(&mathInlineParser{}, 150)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&mathRenderer{}, 500)))
+}
@@ -0,0 +1,59 @@
+package Handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownStripsUnsafeHTML(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		forbidden []string
+	}{
+		{"script tag", "<script>alert(1)</script>", []string{"<script", "alert(1)"}},
+		{"javascript link", "[x](javascript:alert(1))", []string{"javascript:", "href"}},
+		{"event handler on image", "<img src=x onerror=alert(1)>", []string{"onerror", "<img"}},
+		{"event handler on link", `<a href="https://e.com" onclick="x()">l</a>`, []string{"onclick"}},
+		{"style attribute", `<p style="background:url(x)">hi</p>`, []string{"style"}},
+		{"iframe", `<iframe src="https://e.com"></iframe>`, []string{"<iframe"}},
+		{"script inside inline math", "$<script>alert(1)</script>$", []string{"<script"}},
+		{"closing tag inside display math", "$$\n</div><script>x</script>\n$$", []string{"<script", "</div><"}},
+		{"forged math class", `<span class="math math-inline" onmouseover="x()">a</span>`, []string{"onmouseover"}},
+		{"unknown code class", `<code class="evil">x</code>`, []string{"evil"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renderMarkdown(tt.source)
+			for _, f := range tt.forbidden {
+				if strings.Contains(got, f) {
+					t.Errorf("renderMarkdown(%q) = %q, must not contain %q", tt.source, got, f)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"inline math", "$x^2$ here", `<p><span class="math math-inline">\(x^2\)</span> here</p>` + "\n"},
+		{"display math", "$$\nE = mc^2\n$$", `<div class="math math-display">\[E = mc^2` + "\n" + `\]</div>` + "\n"},
+		{"prices are not math", "costs $5 and $6", "<p>costs $5 and $6</p>\n"},
+		{"dollar followed by a space", "$ not math$", "<p>$ not math$</p>\n"},
+		{"math is escaped", "$a<b$", `<p><span class="math math-inline">\(a&lt;b\)</span></p>` + "\n"},
+		{"code block language", "```go\nfmt.Println()\n```", `<pre><code class="language-go">fmt.Println()` + "\n</code></pre>\n"},
+		{"linkified URL", "https://example.com", `<p><a href="https://example.com" rel="nofollow noopener" target="_blank">https://example.com</a></p>` + "\n"},
+		{"task list", "- [x] done", `<ul>` + "\n" + `<li><input checked="" disabled="" type="checkbox"> done</li>` + "\n</ul>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderMarkdown(tt.source); got != tt.want {
+				t.Errorf("renderMarkdown(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,812 @@
+package Handlers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/webp"
+)
+
+const (
+	maxImageUploadSize = 10 << 20
+	maxGIFUploadSize   = 15 << 20
+	maxVideoUploadSize = 100 << 20
+
+	maxResumableVideoSize = 2 << 30
+
+	maxImageDimension = 12000
+	maxImagePixels    = 50000000
+	maxGIFFrames      = 500
+	maxGIFTotalPixels = 100000000
+)
+
+type mediaFormat struct {
+	Name      string
+	MediaType string
+	MimeType  string
+	Exts      []string
+	MaxSize   int64
+}
+
+var mediaFormats = map[string]mediaFormat{
+	"jpeg": {"jpeg", "image", "image/jpeg", []string{".jpg", ".jpeg"}, maxImageUploadSize},
+	"png":  {"png", "image", "image/png", []string{".png"}, maxImageUploadSize},
+	"gif":  {"gif", "image", "image/gif", []string{".gif"}, maxGIFUploadSize},
+	"webp": {"webp", "image", "image/webp", []string{".webp"}, maxImageUploadSize},
+	"mp4":  {"mp4", "video", "video/mp4", []string{".mp4"}, maxVideoUploadSize},
+	"mov":  {"mov", "video", "video/quicktime", []string{".mov"}, maxVideoUploadSize},
+	"avi":  {"avi", "video", "video/x-msvideo", []string{".avi"}, maxVideoUploadSize},
+	"mkv":  {"mkv", "video", "video/x-matroska", []string{".mkv"}, maxVideoUploadSize},
+}
+
+var markupSignatures = [][]byte{
+	[]byte("<script"),
+	[]byte("<html"),
+	[]byte("<!doctype"),
+	[]byte("<svg"),
+	[]byte("<?php"),
+	[]byte("<iframe"),
+	[]byte("javascript:"),
+}
+
+var payloadSignatures = [][]byte{
+	[]byte("PK\x03\x04"),
+	[]byte("PK\x05\x06"),
+	[]byte("Rar!\x1a\x07"),
+	[]byte("7z\xbc\xaf\x27\x1c"),
+	[]byte("\x7fELF"),
+	[]byte("%PDF-"),
+}
+
+type mediaRejectedError struct {
+	message string
+}
+
+func (e *mediaRejectedError) Error() string {
+	return e.message
+}
+
+func rejectMedia(format string, args ...interface{}) error {
+	return &mediaRejectedError{fmt.Sprintf(format, args...)}
+}
+
+type inspectedMedia struct {
+	Format    mediaFormat
+	Width     int
+	Height    int
+	Duration  *float64
+	Sanitized []byte
+}
+
+func sniffMediaFormat(head []byte) (mediaFormat, bool) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return mediaFormats["jpeg"], true
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return mediaFormats["png"], true
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return mediaFormats["gif"], true
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return mediaFormats["webp"], true
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "AVI ":
+		return mediaFormats["avi"], true
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return mediaFormats["mkv"], true
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		if string(head[8:12]) == "qt  " {
+			return mediaFormats["mov"], true
+		}
+		return mediaFormats["mp4"], true
+	case len(head) >= 8 && (string(head[4:8]) == "moov" || string(head[4:8]) == "wide" || string(head[4:8]) == "mdat"):
+		return mediaFormats["mov"], true
+	}
+	return mediaFormat{}, false
+}
+
+func inspectMedia(src io.ReaderAt, size int64, ext string, resumable bool) (*inspectedMedia, error) {
+	head := make([]byte, 16)
+	n, err := src.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	format, ok := sniffMediaFormat(head[:n])
+	if !ok {
+		return nil, rejectMedia("unsupported file type")
+	}
+
+	extMatches := false
+	for _, e := range format.Exts {
+		if e == ext {
+			extMatches = true
+		}
+	}
+	if !extMatches {
+		return nil, rejectMedia("file content (%s) does not match its extension %q", format.MimeType, ext)
+	}
+	maxSize := format.MaxSize
+	if resumable && format.MediaType == "video" {
+		maxSize = maxResumableVideoSize
+	}
+	if size > maxSize {
+		return nil, rejectMedia("%s files must be at most %d MB", format.Name, maxSize>>20)
+	}
+
+	headSize := int64(1024)
+	if size < headSize {
+		headSize = size
+	}
+	markupHead, err := readAtExactly(src, 0, int(headSize))
+	if err != nil {
+		return nil, err
+	}
+	if err := scanMarkup(markupHead); err != nil {
+		return nil, err
+	}
+
+	if format.MediaType == "image" {
+		data := make([]byte, size)
+		if _, err := src.ReadAt(data, 0); err != nil && err != io.EOF {
+			return nil, err
+		}
+		return sanitizeImage(format, data)
+	}
+	return inspectVideo(format, src, size)
+}
+
+func scanMarkup(head []byte) error {
+	lower := bytes.ToLower(head)
+	for _, sig := range markupSignatures {
+		if bytes.Contains(lower, sig) {
+			return rejectMedia("file contains embedded markup (%q) and was rejected", sig)
+		}
+	}
+	return nil
+}
+
+func checkTrailingData(trailer []byte) error {
+	if len(bytes.Trim(trailer, "\x00\r\n\t ")) == 0 {
+		return nil
+	}
+	if err := scanMarkup(trailer); err != nil {
+		return err
+	}
+	for _, sig := range payloadSignatures {
+		if bytes.Contains(trailer, sig) {
+			return rejectMedia("file has an embedded %q payload appended and was rejected", sig)
+		}
+	}
+	return nil
+}
+
+func checkImageDimensions(r io.Reader) error {
+	// Only the header is read, so decompression bombs are rejected before
+	// any pixel buffer is allocated for them.
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return rejectMedia("invalid or unsupported image")
+	}
+	if config.Width > maxImageDimension || config.Height > maxImageDimension || int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return rejectMedia("images must be at most %dx%d pixels and %d megapixels", maxImageDimension, maxImageDimension, maxImagePixels/1000000)
+	}
+	return nil
+}
+
+// checkGIFFrames walks the GIF block structure without decoding it and
+// rejects animations whose frames would together need too much memory once
+// gif.DecodeAll allocates a buffer for each of them.
+func checkGIFFrames(data []byte) error {
+	if len(data) < 13 {
+		return rejectMedia("invalid GIF image")
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	skipSubBlocks := func() bool {
+		for pos < len(data) {
+			size := int(data[pos])
+			pos += 1 + size
+			if size == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	frames := 0
+	var pixels int64
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21:
+			pos += 2
+			if !skipSubBlocks() {
+				return nil
+			}
+		case 0x2C:
+			if pos+10 > len(data) {
+				return nil
+			}
+			width := int64(data[pos+5]) | int64(data[pos+6])<<8
+			height := int64(data[pos+7]) | int64(data[pos+8])<<8
+			frames++
+			pixels += width * height
+			if frames > maxGIFFrames {
+				return rejectMedia("animated GIFs must have at most %d frames", maxGIFFrames)
+			}
+			if pixels > maxGIFTotalPixels {
+				return rejectMedia("animated GIFs must have at most %d megapixels across all frames", maxGIFTotalPixels/1000000)
+			}
+			packed := data[pos+9]
+			pos += 10
+			if packed&0x80 != 0 {
+				pos += 3 << (packed&0x07 + 1)
+			}
+			pos++ // LZW minimum code size
+			if !skipSubBlocks() {
+				return nil
+			}
+		case 0x3B:
+			return nil
+		default:
+			return rejectMedia("invalid GIF image")
+		}
+	}
+	return nil
+}
+
+func sanitizeImage(format mediaFormat, data []byte) (*inspectedMedia, error) {
+	if err := checkImageDimensions(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	result := &inspectedMedia{Format: format}
+	var out bytes.Buffer
+	switch format.Name {
+	case "jpeg":
+		end, err := jpegImageEnd(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkTrailingData(data[end:]); err != nil {
+			return nil, err
+		}
+		img, err := imaging.Decode(bytes.NewReader(data[:end]), imaging.AutoOrientation(true))
+		if err != nil {
+			return nil, rejectMedia("invalid JPEG image")
+		}
+		if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, err
+		}
+		result.Width, result.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	case "png":
+		end, err := pngImageEnd(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkTrailingData(data[end:]); err != nil {
+			return nil, err
+		}
+		img, err := png.Decode(bytes.NewReader(data[:end]))
+		if err != nil {
+			return nil, rejectMedia("invalid PNG image")
+		}
+		if err := png.Encode(&out, img); err != nil {
+			return nil, err
+		}
+		result.Width, result.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	case "gif":
+		if err := checkGIFFrames(data); err != nil {
+			return nil, err
+		}
+		r := bytes.NewReader(data)
+		anim, err := gif.DecodeAll(r)
+		if err != nil {
+			return nil, rejectMedia("invalid GIF image")
+		}
+		if err := checkTrailingData(data[len(data)-r.Len():]); err != nil {
+			return nil, err
+		}
+		if err := gif.EncodeAll(&out, anim); err != nil {
+			return nil, err
+		}
+		result.Width, result.Height = anim.Config.Width, anim.Config.Height
+		if len(anim.Delay) > 1 {
+			total := 0
+			for _, d := range anim.Delay {
+				total += d
+			}
+			duration := float64(total) / 100
+			result.Duration = &duration
+		}
+	case "webp":
+		cleaned, err := stripWebPMetadata(data)
+		if err != nil {
+			return nil, err
+		}
+		img, err := webp.Decode(bytes.NewReader(cleaned))
+		if err != nil {
+			return nil, rejectMedia("invalid or animated WebP image")
+		}
+		out.Write(cleaned)
+		result.Width, result.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	default:
+		return nil, rejectMedia("unsupported image type")
+	}
+
+	result.Sanitized = out.Bytes()
+	return result, nil
+}
+
+func jpegImageEnd(data []byte) (int, error) {
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 0, rejectMedia("invalid JPEG image")
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF:
+			pos++
+			continue
+		case marker == 0xD9:
+			return pos + 2, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			pos += 2
+			continue
+		}
+		segmentEnd := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:pos+4]))
+		if segmentEnd > len(data) {
+			break
+		}
+		pos = segmentEnd
+		if marker != 0xDA {
+			continue
+		}
+		for pos+1 < len(data) && !(data[pos] == 0xFF && data[pos+1] != 0x00 && (data[pos+1] < 0xD0 || data[pos+1] > 0xD7)) {
+			pos++
+		}
+	}
+	if pos+2 <= len(data) && data[pos] == 0xFF && data[pos+1] == 0xD9 {
+		return pos + 2, nil
+	}
+	return 0, rejectMedia("JPEG image is truncated")
+}
+
+func pngImageEnd(data []byte) (int, error) {
+	for pos := 8; pos+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			break
+		}
+		if string(data[pos+4:pos+8]) == "IEND" {
+			return end, nil
+		}
+		pos = end
+	}
+	return 0, rejectMedia("PNG image is truncated")
+}
+
+var webpImageChunks = map[string]bool{
+	"VP8 ": true, "VP8L": true, "VP8X": true, "ALPH": true, "ICCP": true, "ANIM": true, "ANMF": true,
+}
+
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 {
+		return nil, rejectMedia("WebP image is truncated")
+	}
+	riffEnd := int(binary.LittleEndian.Uint32(data[4:8])) + 8
+	if riffEnd > len(data) || riffEnd < 12 {
+		return nil, rejectMedia("WebP image is truncated")
+	}
+	if err := checkTrailingData(data[riffEnd:]); err != nil {
+		return nil, err
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, riffEnd))
+	out.WriteString("RIFF\x00\x00\x00\x00WEBP")
+	for pos := 12; pos < riffEnd; {
+		if pos+8 > riffEnd {
+			return nil, rejectMedia("WebP image is truncated")
+		}
+		fourCC := string(data[pos : pos+4])
+		chunkSize := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		next := pos + 8 + chunkSize + chunkSize%2
+		if chunkSize < 0 || next > riffEnd {
+			return nil, rejectMedia("WebP image is truncated")
+		}
+		switch {
+		case fourCC == "VP8X":
+			chunk := append([]byte(nil), data[pos:next]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		case webpImageChunks[fourCC]:
+			out.Write(data[pos:next])
+		}
+		pos = next
+	}
+
+	cleaned := out.Bytes()
+	binary.LittleEndian.PutUint32(cleaned[4:8], uint32(len(cleaned)-8))
+	return cleaned, nil
+}
+
+func inspectVideo(format mediaFormat, src io.ReaderAt, size int64) (*inspectedMedia, error) {
+	result := &inspectedMedia{Format: format}
+	var err error
+	switch format.Name {
+	case "mp4", "mov":
+		err = inspectISOBMFF(src, size, result)
+	case "avi":
+		err = inspectAVI(src, size, result)
+	case "mkv":
+		err = inspectMatroska(src, size, result)
+	default:
+		err = rejectMedia("unsupported video type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func readAtExactly(src io.ReaderAt, offset int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := src.ReadAt(buf, offset)
+	if read < n {
+		if err == nil || err == io.EOF {
+			return nil, rejectMedia("video file is truncated")
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+type isoBox struct {
+	Type       string
+	Offset     int64
+	HeaderSize int64
+	Size       int64
+}
+
+func readISOBoxes(src io.ReaderAt, start int64, end int64) ([]isoBox, error) {
+	boxes := make([]isoBox, 0)
+	for pos := start; pos < end; {
+		header, err := readAtExactly(src, pos, 8)
+		if err != nil {
+			return nil, err
+		}
+		box := isoBox{Type: string(header[4:8]), Offset: pos, HeaderSize: 8, Size: int64(binary.BigEndian.Uint32(header[0:4]))}
+		switch box.Size {
+		case 0:
+			box.Size = end - pos
+		case 1:
+			large, err := readAtExactly(src, pos+8, 8)
+			if err != nil {
+				return nil, err
+			}
+			box.HeaderSize = 16
+			box.Size = int64(binary.BigEndian.Uint64(large))
+		}
+		if box.Size < box.HeaderSize || pos+box.Size > end {
+			return nil, rejectMedia("video container is malformed or has trailing data")
+		}
+		boxes = append(boxes, box)
+		pos += box.Size
+	}
+	return boxes, nil
+}
+
+func inspectISOBMFF(src io.ReaderAt, size int64, result *inspectedMedia) error {
+	boxes, err := readISOBoxes(src, 0, size)
+	if err != nil {
+		return err
+	}
+
+	knownTopLevel := map[string]bool{
+		"ftyp": true, "moov": true, "mdat": true, "free": true, "skip": true, "wide": true,
+		"uuid": true, "pdin": true, "moof": true, "mfra": true, "meta": true, "styp": true,
+		"sidx": true, "ssix": true, "prft": true, "emsg": true, "pnot": true,
+	}
+	var moov *isoBox
+	for i := range boxes {
+		if !knownTopLevel[boxes[i].Type] {
+			return rejectMedia("video container has unexpected %q data", boxes[i].Type)
+		}
+		if boxes[i].Type == "moov" && moov == nil {
+			moov = &boxes[i]
+		}
+	}
+	if moov == nil {
+		return rejectMedia("video has no movie header")
+	}
+
+	children, err := readISOBoxes(src, moov.Offset+moov.HeaderSize, moov.Offset+moov.Size)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		body, err := readAtExactly(src, child.Offset+child.HeaderSize, int(math.Min(float64(child.Size-child.HeaderSize), 128)))
+		if err != nil {
+			return err
+		}
+		switch child.Type {
+		case "mvhd":
+			var timescale, duration uint64
+			if len(body) >= 32 && body[0] == 1 {
+				timescale = uint64(binary.BigEndian.Uint32(body[20:24]))
+				duration = binary.BigEndian.Uint64(body[24:32])
+			} else if len(body) >= 20 {
+				timescale = uint64(binary.BigEndian.Uint32(body[12:16]))
+				duration = uint64(binary.BigEndian.Uint32(body[16:20]))
+			}
+			if timescale > 0 {
+				seconds := float64(duration) / float64(timescale)
+				result.Duration = &seconds
+			}
+		case "trak":
+			if result.Width > 0 {
+				continue
+			}
+			tracks, err := readISOBoxes(src, child.Offset+child.HeaderSize, child.Offset+child.Size)
+			if err != nil {
+				return err
+			}
+			for _, t := range tracks {
+				if t.Type != "tkhd" || t.Size < t.HeaderSize+8 {
+					continue
+				}
+				dims, err := readAtExactly(src, t.Offset+t.Size-8, 8)
+				if err != nil {
+					return err
+				}
+				result.Width = int(binary.BigEndian.Uint32(dims[0:4]) >> 16)
+				result.Height = int(binary.BigEndian.Uint32(dims[4:8]) >> 16)
+			}
+		}
+	}
+	return nil
+}
+
+func inspectAVI(src io.ReaderAt, size int64, result *inspectedMedia) error {
+	for pos := int64(0); pos < size; {
+		header, err := readAtExactly(src, pos, 12)
+		if err != nil {
+			return err
+		}
+		if string(header[0:4]) != "RIFF" || (string(header[8:12]) != "AVI " && string(header[8:12]) != "AVIX") {
+			return rejectMedia("video container is malformed or has trailing data")
+		}
+		chunkSize := int64(binary.LittleEndian.Uint32(header[4:8]))
+		pos += 8 + chunkSize + chunkSize%2
+		if pos > size {
+			return rejectMedia("video file is truncated")
+		}
+	}
+
+	head, err := readAtExactly(src, 0, int(math.Min(float64(size), 512)))
+	if err != nil {
+		return err
+	}
+	idx := bytes.Index(head, []byte("avih"))
+	if idx < 0 || idx+8+40 > len(head) {
+		return rejectMedia("video has no AVI header")
+	}
+	avih := head[idx+8:]
+	microSecPerFrame := binary.LittleEndian.Uint32(avih[0:4])
+	totalFrames := binary.LittleEndian.Uint32(avih[16:20])
+	result.Width = int(binary.LittleEndian.Uint32(avih[32:36]))
+	result.Height = int(binary.LittleEndian.Uint32(avih[36:40]))
+	if microSecPerFrame > 0 && totalFrames > 0 {
+		seconds := float64(microSecPerFrame) * float64(totalFrames) / 1e6
+		result.Duration = &seconds
+	}
+	return nil
+}
+
+const (
+	ebmlHeaderID     = 0x1A45DFA3
+	ebmlDocTypeID    = 0x4282
+	mkvSegmentID     = 0x18538067
+	mkvInfoID        = 0x1549A966
+	mkvTimecodeScale = 0x2AD7B1
+	mkvDurationID    = 0x4489
+	mkvTracksID      = 0x1654AE6B
+	mkvTrackEntryID  = 0xAE
+	mkvVideoID       = 0xE0
+	mkvPixelWidthID  = 0xB0
+	mkvPixelHeightID = 0xBA
+)
+
+type ebmlElement struct {
+	ID         uint64
+	DataOffset int64
+	Size       int64
+	Unknown    bool
+}
+
+func readEBMLVint(src io.ReaderAt, pos int64, keepMarker bool) (uint64, int, bool, error) {
+	first, err := readAtExactly(src, pos, 1)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, false, rejectMedia("video container is malformed")
+	}
+	raw, err := readAtExactly(src, pos, length)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	value := uint64(raw[0])
+	if !keepMarker {
+		value &= uint64(0xFF >> length)
+	}
+	allOnes := value == uint64(0xFF>>length)
+	for _, b := range raw[1:] {
+		value = value<<8 | uint64(b)
+		allOnes = allOnes && b == 0xFF
+	}
+	return value, length, allOnes && !keepMarker, nil
+}
+
+func readEBMLElement(src io.ReaderAt, pos int64) (ebmlElement, error) {
+	id, idLen, _, err := readEBMLVint(src, pos, true)
+	if err != nil {
+		return ebmlElement{}, err
+	}
+	size, sizeLen, unknown, err := readEBMLVint(src, pos+int64(idLen), false)
+	if err != nil {
+		return ebmlElement{}, err
+	}
+	return ebmlElement{ID: id, DataOffset: pos + int64(idLen) + int64(sizeLen), Size: int64(size), Unknown: unknown}, nil
+}
+
+func readEBMLChildren(src io.ReaderAt, start int64, end int64, visit func(ebmlElement) error) error {
+	for pos := start; pos < end; {
+		el, err := readEBMLElement(src, pos)
+		if err != nil {
+			return err
+		}
+		if el.Unknown || el.DataOffset+el.Size > end {
+			return nil
+		}
+		if err := visit(el); err != nil {
+			return err
+		}
+		pos = el.DataOffset + el.Size
+	}
+	return nil
+}
+
+func readEBMLUint(src io.ReaderAt, el ebmlElement) (uint64, error) {
+	if el.Size > 8 {
+		return 0, rejectMedia("video container is malformed")
+	}
+	raw, err := readAtExactly(src, el.DataOffset, int(el.Size))
+	if err != nil {
+		return 0, err
+	}
+	var value uint64
+	for _, b := range raw {
+		value = value<<8 | uint64(b)
+	}
+	return value, nil
+}
+
+func inspectMatroska(src io.ReaderAt, size int64, result *inspectedMedia) error {
+	header, err := readEBMLElement(src, 0)
+	if err != nil {
+		return err
+	}
+	if header.ID != ebmlHeaderID || header.Unknown || header.DataOffset+header.Size > size {
+		return rejectMedia("video container is malformed")
+	}
+	docType := ""
+	err = readEBMLChildren(src, header.DataOffset, header.DataOffset+header.Size, func(el ebmlElement) error {
+		if el.ID == ebmlDocTypeID && el.Size <= 16 {
+			raw, err := readAtExactly(src, el.DataOffset, int(el.Size))
+			if err != nil {
+				return err
+			}
+			docType = string(bytes.TrimRight(raw, "\x00"))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if docType != "matroska" && docType != "webm" {
+		return rejectMedia("video container is not Matroska")
+	}
+
+	segment, err := readEBMLElement(src, header.DataOffset+header.Size)
+	if err != nil {
+		return err
+	}
+	if segment.ID != mkvSegmentID {
+		return rejectMedia("video container is malformed")
+	}
+	segmentEnd := size
+	if !segment.Unknown {
+		segmentEnd = segment.DataOffset + segment.Size
+		if segmentEnd != size {
+			return rejectMedia("video container is malformed or has trailing data")
+		}
+	}
+
+	timecodeScale := uint64(1000000)
+	var rawDuration float64
+	return readEBMLChildren(src, segment.DataOffset, segmentEnd, func(el ebmlElement) error {
+		switch el.ID {
+		case mkvInfoID:
+			err := readEBMLChildren(src, el.DataOffset, el.DataOffset+el.Size, func(info ebmlElement) error {
+				switch info.ID {
+				case mkvTimecodeScale:
+					scale, err := readEBMLUint(src, info)
+					if err != nil {
+						return err
+					}
+					if scale > 0 {
+						timecodeScale = scale
+					}
+				case mkvDurationID:
+					bits, err := readEBMLUint(src, info)
+					if err != nil {
+						return err
+					}
+					if info.Size == 4 {
+						rawDuration = float64(math.Float32frombits(uint32(bits)))
+					} else if info.Size == 8 {
+						rawDuration = math.Float64frombits(bits)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if rawDuration > 0 {
+				seconds := rawDuration * float64(timecodeScale) / 1e9
+				result.Duration = &seconds
+			}
+		case mkvTracksID:
+			return readEBMLChildren(src, el.DataOffset, el.DataOffset+el.Size, func(track ebmlElement) error {
+				if track.ID != mkvTrackEntryID || result.Width > 0 {
+					return nil
+				}
+				return readEBMLChildren(src, track.DataOffset, track.DataOffset+track.Size, func(entry ebmlElement) error {
+					if entry.ID != mkvVideoID {
+						return nil
+					}
+					return readEBMLChildren(src, entry.DataOffset, entry.DataOffset+entry.Size, func(video ebmlElement) error {
+						value, err := readEBMLUint(src, video)
+						if err != nil {
+							return err
+						}
+						switch video.ID {
+						case mkvPixelWidthID:
+							result.Width = int(value)
+						case mkvPixelHeightID:
+							result.Height = int(value)
+						}
+						return nil
+					})
+				})
+			})
+		}
+		return nil
+	})
+}
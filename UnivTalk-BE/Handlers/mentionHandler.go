@@ -0,0 +1,189 @@
+package Handlers
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const maxMentionsPerBody = 20
+
+var (
+	mentionCodePattern = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+	mentionPattern     = regexp.MustCompile(`(?:^|[^\w@/.])@([A-Za-z0-9_][A-Za-z0-9_.-]{0,31})`)
+)
+
+func parseMentions(body string) []string {
+	body = mentionCodePattern.ReplaceAllString(body, " ")
+	seen := make(map[string]bool)
+	usernames := make([]string, 0)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+		if len(usernames) == maxMentionsPerBody {
+			break
+		}
+	}
+	return usernames
+}
+
+func mentionRecipients(db *pg.DB, authorID uuid.UUID, forumID uuid.UUID, body string) ([]uuid.UUID, error) {
+	usernames := parseMentions(body)
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	var users []Models.Users
+	if err := db.Model(&users).Column("uid").Where("username IN (?)", pg.In(usernames)).Select(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	recipients := make([]uuid.UUID, 0, len(users))
+	for _, u := range users {
+		allowed, err := canNotifyAbout(db, u.UID, authorID, forumID)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			recipients = append(recipients, u.UID)
+		}
+	}
+	return recipients, nil
+}
+
+func syncMentions(db *pg.DB, authorID uuid.UUID, forumID uuid.UUID, postID int, commentID *int, body string) {
+	recipients, err := mentionRecipients(db, authorID, forumID, body)
+	if err != nil {
+		log.Printf("Sync Mentions Failed (post %d): %v", postID, err)
+		return
+	}
+
+	var existing []Models.Mentions
+	query := db.Model(&existing).Where("post_id = ?", postID)
+	if commentID != nil {
+		query.Where("comment_id = ?", *commentID)
+	} else {
+		query.Where("comment_id IS NULL")
+	}
+	if err := query.Select(); err != nil {
+		log.Printf("Sync Mentions Failed (post %d): %v", postID, err)
+		return
+	}
+
+	wanted := make(map[uuid.UUID]bool, len(recipients))
+	for _, id := range recipients {
+		wanted[id] = true
+	}
+	already := make(map[uuid.UUID]bool, len(existing))
+	removed := make([]int, 0)
+	removedUsers := make([]uuid.UUID, 0)
+	for _, m := range existing {
+		already[m.UserID] = true
+		if !wanted[m.UserID] {
+			removed = append(removed, m.ID)
+			removedUsers = append(removedUsers, m.UserID)
+		}
+	}
+
+	if len(removed) > 0 {
+		if _, err := db.Model((*Models.Mentions)(nil)).Where("id IN (?)", pg.In(removed)).Delete(); err != nil {
+			log.Printf("Sync Mentions Failed (post %d): %v", postID, err)
+			return
+		}
+		unread := db.Model((*Models.Notifications)(nil)).
+			Where("type = ?", notifyMention).
+			Where("user_id IN (?)", pg.In(removedUsers)).
+			Where("post_id = ?", postID).
+			Where("read_at IS NULL")
+		if commentID != nil {
+			unread.Where("comment_id = ?", *commentID)
+		} else {
+			unread.Where("comment_id IS NULL")
+		}
+		if _, err := unread.Delete(); err != nil {
+			log.Printf("Sync Mentions Failed (post %d): %v", postID, err)
+		}
+	}
+
+	now := time.Now()
+	for _, userID := range recipients {
+		if already[userID] {
+			continue
+		}
+		mention := &Models.Mentions{
+			UserID:        userID,
+			MentionedByID: authorID,
+			ForumID:       forumID,
+			PostID:        postID,
+			CommentID:     commentID,
+			CreatedAt:     now,
+		}
+		if _, err := db.Model(mention).Insert(); err != nil {
+			log.Printf("Sync Mentions Failed (post %d): %v", postID, err)
+			continue
+		}
+		actorID, notifyForumID, notifyPostID := authorID, forumID, postID
+		createNotification(db, &Models.Notifications{
+			UserID:    userID,
+			ActorID:   &actorID,
+			Type:      notifyMention,
+			ForumID:   &notifyForumID,
+			PostID:    &notifyPostID,
+			CommentID: commentID,
+		})
+	}
+}
+
+func GetMentions(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	mentions := make([]Models.Mentions, 0)
+	err = db.Model(&mentions).
+		Relation("Author.uid").
+		Relation("Author.username").
+		Relation("Post").
+		Where("mentions.user_id = ?", userID).
+		Where("mentions.post_id IN (SELECT id FROM posts WHERE deleted_at IS NULL)").
+		Where("mentions.comment_id IS NULL OR mentions.comment_id IN (SELECT id FROM comments WHERE deleted_at IS NULL)").
+		Where("mentions.forum_id IN (SELECT fid FROM forums WHERE NOT is_private UNION SELECT forum_id FROM forum_members WHERE user_id = ?)", userID).
+		Where("mentions.mentioned_by NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?)", userID).
+		Order("mentions.created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Select()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve mentions", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mentions": mentions})
+}
@@ -0,0 +1,970 @@
+package Handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	maxGroupMembers      = 10
+	maxMessageLength     = 4000
+	maxConversationTitle = 100
+)
+
+func directConversationKey(a uuid.UUID, b uuid.UUID) string {
+	if a.String() > b.String() {
+		a, b = b, a
+	}
+	return a.String() + ":" + b.String()
+}
+
+func encodeConversationCursor(conversation *Models.Conversations) string {
+	raw := conversation.LastMessageAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(conversation.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeConversationCursor(cursor string) (time.Time, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor")
+	}
+	rawAt, rawID, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor")
+	}
+	at, err := time.Parse(time.RFC3339Nano, rawAt)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor")
+	}
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor")
+	}
+	return at, id, nil
+}
+
+func messagePageLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	return limit
+}
+
+func bindConversationUserIDs(raw []string, userID uuid.UUID) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(raw))
+	seen := map[uuid.UUID]bool{userID: true}
+	for _, r := range raw {
+		id, err := uuid.Parse(strings.TrimSpace(r))
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID %q", r)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("at least one other user is required")
+	}
+	return ids, nil
+}
+
+func checkMessageRecipients(db *pg.DB, userID uuid.UUID, recipients []uuid.UUID) (int, gin.H) {
+	count, err := db.Model((*Models.Users)(nil)).
+		Where("uid IN (?)", pg.In(recipients)).
+		Where("status = ?", "active").
+		Count()
+	if err != nil {
+		return http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"}
+	}
+	if count != len(recipients) {
+		return http.StatusNotFound, gin.H{"error": "User not found"}
+	}
+	for _, recipient := range recipients {
+		blocked, err := isBlockedBetween(db, userID, recipient)
+		if err != nil {
+			return http.StatusInternalServerError, gin.H{"error": "Failed to verify blocked users"}
+		}
+		if blocked {
+			return http.StatusForbidden, gin.H{"error": "You cannot message this user", "user_id": recipient}
+		}
+	}
+	return 0, nil
+}
+
+func getConversationForMember(c *gin.Context, db *pg.DB, userID uuid.UUID) (*Models.Conversations, *Models.ConversationMembers, bool) {
+	conversationID, err := strconv.Atoi(c.Param("conversation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Conversation ID format"})
+		return nil, nil, false
+	}
+
+	member := &Models.ConversationMembers{ConversationID: conversationID, UserID: userID}
+	err = db.Model(member).WherePK().Select()
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return nil, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve conversation"})
+		return nil, nil, false
+	}
+
+	conversation := &Models.Conversations{ID: conversationID}
+	if err := db.Model(conversation).WherePK().Select(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve conversation"})
+		return nil, nil, false
+	}
+	return conversation, member, true
+}
+
+func conversationMemberIDs(db *pg.DB, conversationID int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := db.Model((*Models.ConversationMembers)(nil)).
+		Column("user_id").
+		Where("conversation_id = ?", conversationID).
+		Select(&ids)
+	return ids, err
+}
+
+func publishToConversation(db *pg.DB, conversationID int, actorID uuid.UUID, eventType string, data interface{}) {
+	var recipients []uuid.UUID
+	err := db.Model((*Models.ConversationMembers)(nil)).
+		Column("user_id").
+		Where("conversation_id = ?", conversationID).
+		Where("user_id NOT IN (SELECT blocker_id FROM user_blocks WHERE blocked_id = ?)", actorID).
+		Select(&recipients)
+	if err != nil {
+		return
+	}
+	for _, recipient := range recipients {
+		hub.publish(userTopic(recipient), eventType, data)
+	}
+}
+
+func loadMessageAttachments(db *pg.DB, messages []Models.Messages) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.ID)
+	}
+
+	var attachments []Models.MessageAttachments
+	err := db.Model(&attachments).
+		Where("message_id IN (?)", pg.In(ids)).
+		Order("message_id ASC", "position ASC").
+		Select()
+	if err != nil {
+		return err
+	}
+	byMessage := make(map[int][]Models.MessageAttachments, len(messages))
+	for _, a := range attachments {
+		a.URL = resolveMediaURL(a.StorageKey, true)
+		byMessage[a.MessageID] = append(byMessage[a.MessageID], a)
+	}
+	for i := range messages {
+		messages[i].Attachments = byMessage[messages[i].ID]
+		if messages[i].Attachments == nil {
+			messages[i].Attachments = []Models.MessageAttachments{}
+		}
+	}
+	return nil
+}
+
+func hydrateConversations(db *pg.DB, userID uuid.UUID, conversations []Models.Conversations) error {
+	if len(conversations) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(conversations))
+	for _, conv := range conversations {
+		ids = append(ids, conv.ID)
+	}
+
+	var members []Models.ConversationMembers
+	err := db.Model(&members).
+		Relation("User.uid").
+		Relation("User.username").
+		Where("conversation_members.conversation_id IN (?)", pg.In(ids)).
+		Order("conversation_members.joined_at ASC").
+		Select()
+	if err != nil {
+		return err
+	}
+
+	var lastMessages []Models.Messages
+	err = db.Model(&lastMessages).
+		Relation("Sender.uid").
+		Relation("Sender.username").
+		Where(`messages.id IN (
+			SELECT MAX(m.id) FROM messages m
+			WHERE m.conversation_id IN (?)
+			  AND m.sender_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?)
+			GROUP BY m.conversation_id
+		)`, pg.In(ids), userID).
+		Select()
+	if err != nil {
+		return err
+	}
+	if err := loadMessageAttachments(db, lastMessages); err != nil {
+		return err
+	}
+
+	var unread []struct {
+		ConversationID int
+		Count          int
+	}
+	_, err = db.Query(&unread, `
+		SELECT m.conversation_id, COUNT(*) AS count
+		FROM messages m
+		JOIN conversation_members cm ON cm.conversation_id = m.conversation_id AND cm.user_id = ?0
+		WHERE m.conversation_id IN (?1)
+		  AND m.id > cm.last_read_message_id
+		  AND m.sender_id <> ?0
+		  AND m.deleted_at IS NULL
+		  AND m.sender_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?0)
+		GROUP BY m.conversation_id
+	`, userID, pg.In(ids))
+	if err != nil {
+		return err
+	}
+
+	membersByConversation := make(map[int][]Models.ConversationMembers, len(conversations))
+	for _, m := range members {
+		membersByConversation[m.ConversationID] = append(membersByConversation[m.ConversationID], m)
+	}
+	lastByConversation := make(map[int]*Models.Messages, len(lastMessages))
+	for i := range lastMessages {
+		lastByConversation[lastMessages[i].ConversationID] = &lastMessages[i]
+	}
+	unreadByConversation := make(map[int]int, len(unread))
+	for _, row := range unread {
+		unreadByConversation[row.ConversationID] = row.Count
+	}
+	for i := range conversations {
+		conv := &conversations[i]
+		conv.Members = membersByConversation[conv.ID]
+		conv.LastMessage = lastByConversation[conv.ID]
+		conv.UnreadCount = unreadByConversation[conv.ID]
+	}
+	return nil
+}
+
+func respondWithConversation(c *gin.Context, db *pg.DB, userID uuid.UUID, status int, message string, conversation *Models.Conversations) {
+	conversations := []Models.Conversations{*conversation}
+	if err := hydrateConversations(db, userID, conversations); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve conversation", "detail": err.Error()})
+		return
+	}
+	response := gin.H{"conversation": conversations[0]}
+	if message != "" {
+		response["message"] = message
+	}
+	c.JSON(status, response)
+}
+
+func GetConversations(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := messagePageLimit(c)
+	conversations := make([]Models.Conversations, 0)
+	query := db.Model(&conversations).
+		Where("conversations.id IN (SELECT conversation_id FROM conversation_members WHERE user_id = ?)", userID)
+	if cursor := c.Query("cursor"); cursor != "" {
+		at, id, err := decodeConversationCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query.Where("(conversations.last_message_at, conversations.id) < (?, ?)", at, id)
+	}
+	err = query.
+		Order("conversations.last_message_at DESC", "conversations.id DESC").
+		Limit(limit + 1).
+		Select()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve conversations", "detail": err.Error()})
+		return
+	}
+
+	nextCursor := ""
+	if len(conversations) > limit {
+		conversations = conversations[:limit]
+		nextCursor = encodeConversationCursor(&conversations[limit-1])
+	}
+	if err := hydrateConversations(db, userID, conversations); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve conversations", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversations": conversations, "next_cursor": nextCursor})
+}
+
+func CreateConversation(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var payload struct {
+		UserIDs []string `json:"user_ids" binding:"required"`
+		Title   string   `json:"title"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+	recipients, err := bindConversationUserIDs(payload.UserIDs, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+	if len(recipients)+1 > maxGroupMembers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A conversation can have at most %d members", maxGroupMembers)})
+		return
+	}
+	title := strings.TrimSpace(payload.Title)
+	if len(title) > maxConversationTitle {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Title must be at most %d characters", maxConversationTitle)})
+		return
+	}
+	if status, body := checkMessageRecipients(db, userID, recipients); body != nil {
+		c.JSON(status, body)
+		return
+	}
+
+	now := time.Now()
+	conversation := &Models.Conversations{
+		IsGroup:       len(recipients) > 1,
+		CreatedBy:     userID,
+		CreatedAt:     now,
+		LastMessageAt: now,
+	}
+	if conversation.IsGroup {
+		conversation.Title = title
+	} else {
+		key := directConversationKey(userID, recipients[0])
+		conversation.DirectKey = &key
+	}
+
+	created := false
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		res, err := tx.Model(conversation).OnConflict("(direct_key) DO NOTHING").Insert()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return tx.Model(conversation).Where("direct_key = ?", *conversation.DirectKey).Select()
+		}
+		created = true
+
+		members := []Models.ConversationMembers{{ConversationID: conversation.ID, UserID: userID, Role: "owner", JoinedAt: now}}
+		for _, recipient := range recipients {
+			members = append(members, Models.ConversationMembers{ConversationID: conversation.ID, UserID: recipient, Role: "member", JoinedAt: now})
+		}
+		_, err = tx.Model(&members).Insert()
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create conversation", "detail": err.Error()})
+		return
+	}
+
+	if !created {
+		respondWithConversation(c, db, userID, http.StatusOK, "", conversation)
+		return
+	}
+	publishToConversation(db, conversation.ID, userID, "conversation.updated", gin.H{"conversation_id": conversation.ID})
+	respondWithConversation(c, db, userID, http.StatusCreated, "Conversation created", conversation)
+}
+
+func GetConversation(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	conversation, _, ok := getConversationForMember(c, db, userID)
+	if !ok {
+		return
+	}
+	respondWithConversation(c, db, userID, http.StatusOK, "", conversation)
+}
+
+func UpdateConversation(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	conversation, member, ok := getConversationForMember(c, db, userID)
+	if !ok {
+		return
+	}
+	if !conversation.IsGroup {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only group conversations can be renamed"})
+		return
+	}
+	if member.Role != "owner" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the group owner can rename the conversation"})
+		return
+	}
+
+	var payload struct {
+		Title string `json:"title"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+	title := strings.TrimSpace(payload.Title)
+	if len(title) > maxConversationTitle {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Title must be at most %d characters", maxConversationTitle)})
+		return
+	}
+
+	conversation.Title = title
+	if _, err := db.Model(conversation).Set("title = ?title").WherePK().Update(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update conversation"})
+		return
+	}
+
+	publishToConversation(db, conversation.ID, userID, "conversation.updated", gin.H{"conversation_id": conversation.ID})
+	respondWithConversation(c, db, userID, http.StatusOK, "Conversation updated", conversation)
+}
+
+func AddConversationMembers(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	conversation, member, ok := getConversationForMember(c, db, userID)
+	if !ok {
+		return
+	}
+	if !conversation.IsGroup {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Members can only be added to group conversations"})
+		return
+	}
+	if member.Role != "owner" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the group owner can add members"})
+		return
+	}
+
+	var payload struct {
+		UserIDs []string `json:"user_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+	recipients, err := bindConversationUserIDs(payload.UserIDs, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+
+	existing, err := conversationMemberIDs(db, conversation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve members"})
+		return
+	}
+	isMember := make(map[uuid.UUID]bool, len(existing))
+	for _, id := range existing {
+		isMember[id] = true
+	}
+	added := make([]uuid.UUID, 0, len(recipients))
+	for _, id := range recipients {
+		if !isMember[id] {
+			added = append(added, id)
+		}
+	}
+	if len(added) == 0 {
+		respondWithConversation(c, db, userID, http.StatusOK, "Members already in the conversation", conversation)
+		return
+	}
+	if len(existing)+len(added) > maxGroupMembers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A conversation can have at most %d members", maxGroupMembers)})
+		return
+	}
+	if status, body := checkMessageRecipients(db, userID, added); body != nil {
+		c.JSON(status, body)
+		return
+	}
+
+	now := time.Now()
+	members := make([]Models.ConversationMembers, 0, len(added))
+	for _, id := range added {
+		members = append(members, Models.ConversationMembers{ConversationID: conversation.ID, UserID: id, Role: "member", JoinedAt: now})
+	}
+	if _, err := db.Model(&members).OnConflict("DO NOTHING").Insert(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add members", "detail": err.Error()})
+		return
+	}
+
+	publishToConversation(db, conversation.ID, userID, "conversation.updated", gin.H{"conversation_id": conversation.ID})
+	respondWithConversation(c, db, userID, http.StatusOK, "Members added", conversation)
+}
+
+func RemoveConversationMember(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	conversation, member, ok := getConversationForMember(c, db, userID)
+	if !ok {
+		return
+	}
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+	if !conversation.IsGroup {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Members can only be removed from group conversations"})
+		return
+	}
+	if targetID != userID && member.Role != "owner" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the group owner can remove members"})
+		return
+	}
+
+	var removedKeys []string
+	deleted := false
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		var target Models.ConversationMembers
+		res, err := tx.Model(&target).
+			Where("conversation_id = ?", conversation.ID).
+			Where("user_id = ?", targetID).
+			Returning("*").
+			Delete()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+
+		remaining, err := tx.Model((*Models.ConversationMembers)(nil)).Where("conversation_id = ?", conversation.ID).Count()
+		if err != nil {
+			return err
+		}
+		if remaining == 0 {
+			_, err := tx.Query(&removedKeys, `
+				SELECT a.storage_key FROM message_attachments a
+				JOIN messages m ON m.id = a.message_id
+				WHERE m.conversation_id = ?
+			`, conversation.ID)
+			if err != nil {
+				return err
+			}
+			deleted = true
+			_, err = tx.Model(conversation).WherePK().Delete()
+			return err
+		}
+		if target.Role != "owner" {
+			return nil
+		}
+		_, err = tx.Exec(`
+			UPDATE conversation_members SET role = 'owner'
+			WHERE conversation_id = ?0 AND user_id = (
+				SELECT user_id FROM conversation_members WHERE conversation_id = ?0 ORDER BY joined_at ASC LIMIT 1
+			)
+		`, conversation.ID)
+		return err
+	})
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this conversation"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member", "detail": err.Error()})
+		return
+	}
+
+	if deleted {
+		deleteStoredMedia(removedKeys)
+		c.JSON(http.StatusOK, gin.H{"message": "Conversation deleted"})
+		return
+	}
+	event := gin.H{"conversation_id": conversation.ID}
+	publishToConversation(db, conversation.ID, userID, "conversation.updated", event)
+	hub.publish(userTopic(targetID), "conversation.updated", event)
+	if targetID == userID {
+		c.JSON(http.StatusOK, gin.H{"message": "You left the conversation"})
+		return
+	}
+	respondWithConversation(c, db, userID, http.StatusOK, "Member removed", conversation)
+}
+
+func GetMessages(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	conversation, _, ok := getConversationForMember(c, db, userID)
+	if !ok {
+		return
+	}
+
+	limit := messagePageLimit(c)
+	messages := make([]Models.Messages, 0)
+	query := db.Model(&messages).
+		Relation("Sender.uid").
+		Relation("Sender.username").
+		Where("messages.conversation_id = ?", conversation.ID).
+		Where("messages.sender_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?)", userID)
+	if cursor := c.Query("cursor"); cursor != "" {
+		before, err := strconv.Atoi(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		query.Where("messages.id < ?", before)
+	}
+	if err := query.Order("messages.id DESC").Limit(limit + 1).Select(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve messages", "detail": err.Error()})
+		return
+	}
+
+	nextCursor := ""
+	if len(messages) > limit {
+		messages = messages[:limit]
+		nextCursor = strconv.Itoa(messages[limit-1].ID)
+	}
+	if err := loadMessageAttachments(db, messages); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attachments", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages, "next_cursor": nextCursor})
+}
+
+func SendMessage(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File upload error"})
+			return
+		}
+	}
+	conversation, _, ok := getConversationForMember(c, db, userID)
+	if !ok {
+		return
+	}
+
+	if !conversation.IsGroup {
+		memberIDs, err := conversationMemberIDs(db, conversation.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve members"})
+			return
+		}
+		for _, id := range memberIDs {
+			if id == userID {
+				continue
+			}
+			blocked, err := isBlockedBetween(db, userID, id)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify blocked users"})
+				return
+			}
+			if blocked {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You cannot message this user"})
+				return
+			}
+		}
+	}
+
+	body := strings.TrimSpace(c.PostForm("body"))
+	if len(body) > maxMessageLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Message must be at most %d characters", maxMessageLength)})
+		return
+	}
+
+	files := uploadedMediaFiles(c)
+	uploads, err := loadCompletedUploads(db, userID, uploadIDsFromRequest(c))
+	if err == nil {
+		err = validateUploadedMedia(c, files, len(uploads))
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media", "detail": err.Error()})
+		return
+	}
+	if body == "" && len(files)+len(uploads) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message body or media is required"})
+		return
+	}
+	incoming, reserved := incomingMediaSize(files, uploads)
+	err = checkStorageQuota(db, userID, uuid.Nil, incoming, reserved)
+	if exceeded, ok := err.(*quotaExceededError); ok {
+		c.JSON(http.StatusRequestEntityTooLarge, quotaExceededResponse(exceeded))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+		return
+	}
+	saved, err := saveUploadedAttachments(c, files, uploads, true)
+	if rejected, ok := err.(*mediaRejectedError); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media", "detail": rejected.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return
+	}
+
+	now := time.Now()
+	message := Models.Messages{
+		ConversationID: conversation.ID,
+		SenderID:       userID,
+		Body:           body,
+		CreatedAt:      now,
+		Attachments:    make([]Models.MessageAttachments, 0, len(saved)),
+	}
+	for i, a := range saved {
+		message.Attachments = append(message.Attachments, Models.MessageAttachments{
+			Position:   i + 1,
+			StorageKey: a.StorageKey,
+			MediaType:  a.MediaType,
+			MimeType:   a.MimeType,
+			SizeBytes:  a.SizeBytes,
+			Checksum:   a.Checksum,
+			Width:      a.Width,
+			Height:     a.Height,
+			Duration:   a.Duration,
+			Caption:    a.Caption,
+			AltText:    a.AltText,
+			CreatedAt:  now,
+		})
+	}
+
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		if err := checkStorageQuotaLocked(tx, userID, uuid.Nil, incoming, reserved); err != nil {
+			return err
+		}
+		if _, err := tx.Model(&message).Insert(); err != nil {
+			return err
+		}
+		if len(message.Attachments) > 0 {
+			for i := range message.Attachments {
+				message.Attachments[i].MessageID = message.ID
+			}
+			if _, err := tx.Model(&message.Attachments).Insert(); err != nil {
+				return err
+			}
+		}
+		if err := consumeUploads(tx, uploads); err != nil {
+			return err
+		}
+		_, err := tx.Model(conversation).Set("last_message_at = ?", now).WherePK().Update()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Model((*Models.ConversationMembers)(nil)).
+			Set("last_read_message_id = ?", message.ID).
+			Set("last_read_at = ?", now).
+			Where("conversation_id = ?", conversation.ID).
+			Where("user_id = ?", userID).
+			Update()
+		return err
+	})
+	if err == errUploadConsumed {
+		removeAttachmentFiles(saved)
+		c.JSON(http.StatusConflict, gin.H{"error": "Invalid media", "detail": err.Error()})
+		return
+	}
+	if exceeded, ok := err.(*quotaExceededError); ok {
+		removeAttachmentFiles(saved)
+		c.JSON(http.StatusRequestEntityTooLarge, quotaExceededResponse(exceeded))
+		return
+	}
+	if err != nil {
+		removeAttachmentFiles(saved)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message", "detail": err.Error()})
+		return
+	}
+
+	removeConsumedUploads(uploads)
+	for i := range message.Attachments {
+		message.Attachments[i].URL = resolveMediaURL(message.Attachments[i].StorageKey, true)
+	}
+	var sender Models.Users
+	if err := db.Model(&sender).Where("uid = ?", userID).Select(); err == nil {
+		message.Sender = &sender
+	}
+	publishToConversation(db, conversation.ID, userID, "message.created", message)
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Message sent", "direct_message": message})
+}
+
+func DeleteMessage(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	conversation, _, ok := getConversationForMember(c, db, userID)
+	if !ok {
+		return
+	}
+	messageID, err := strconv.Atoi(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Message ID format"})
+		return
+	}
+
+	var message Models.Messages
+	err = db.Model(&message).
+		Where("id = ?", messageID).
+		Where("conversation_id = ?", conversation.ID).
+		Select()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	if message.SenderID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own messages"})
+		return
+	}
+	if message.DeletedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Message is already deleted"})
+		return
+	}
+
+	var keys []string
+	now := time.Now()
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		err := tx.Model((*Models.MessageAttachments)(nil)).
+			Column("storage_key").
+			Where("message_id = ?", messageID).
+			Select(&keys)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Model((*Models.MessageAttachments)(nil)).Where("message_id = ?", messageID).Delete()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Model(&message).
+			Set("body = ''").
+			Set("deleted_at = ?", now).
+			WherePK().
+			Update()
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message", "detail": err.Error()})
+		return
+	}
+
+	deleteStoredMedia(keys)
+	publishToConversation(db, conversation.ID, userID, "message.deleted", gin.H{"conversation_id": conversation.ID, "message_id": messageID})
+	c.JSON(http.StatusOK, gin.H{"message": "Message deleted"})
+}
+
+func MarkConversationRead(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	conversation, member, ok := getConversationForMember(c, db, userID)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		MessageID int `json:"message_id"`
+	}
+	c.ShouldBindJSON(&payload)
+
+	readUpTo := payload.MessageID
+	query := db.Model((*Models.Messages)(nil)).Where("conversation_id = ?", conversation.ID)
+	if readUpTo > 0 {
+		query.Where("id = ?", readUpTo)
+	}
+	err = query.ColumnExpr("COALESCE(MAX(id), 0)").Select(&readUpTo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve messages"})
+		return
+	}
+	if readUpTo == 0 && payload.MessageID > 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	if readUpTo <= member.LastReadMessageID {
+		c.JSON(http.StatusOK, gin.H{"message": "Conversation marked as read", "last_read_message_id": member.LastReadMessageID})
+		return
+	}
+
+	now := time.Now()
+	_, err = db.Model((*Models.ConversationMembers)(nil)).
+		Set("last_read_message_id = ?", readUpTo).
+		Set("last_read_at = ?", now).
+		Where("conversation_id = ?", conversation.ID).
+		Where("user_id = ?", userID).
+		Where("last_read_message_id < ?", readUpTo).
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark conversation as read"})
+		return
+	}
+
+	publishToConversation(db, conversation.ID, userID, "message.read", gin.H{
+		"conversation_id":      conversation.ID,
+		"user_id":              userID,
+		"last_read_message_id": readUpTo,
+		"read_at":              now,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation marked as read", "last_read_message_id": readUpTo})
+}
+
+func GetUnreadMessageCount(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var unread, conversations int
+	_, err = db.QueryOne(pg.Scan(&unread, &conversations), `
+		SELECT COUNT(*), COUNT(DISTINCT m.conversation_id)
+		FROM messages m
+		JOIN conversation_members cm ON cm.conversation_id = m.conversation_id AND cm.user_id = ?0
+		WHERE m.id > cm.last_read_message_id
+		  AND m.sender_id <> ?0
+		  AND m.deleted_at IS NULL
+		  AND m.sender_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?0)
+	`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread": unread, "conversations": conversations})
+}
@@ -0,0 +1,431 @@
+package Handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	notifyMention             = "mention"
+	notifyPostReply           = "post_reply"
+	notifyCommentReply        = "comment_reply"
+	notifyVoteMilestone       = "vote_milestone"
+	notifyJoinRequest         = "join_request"
+	notifyJoinRequestDecision = "join_request_decision"
+	notifyModeration          = "moderation"
+)
+
+var (
+	notificationTypes = []string{
+		notifyMention,
+		notifyPostReply,
+		notifyCommentReply,
+		notifyVoteMilestone,
+		notifyJoinRequest,
+		notifyJoinRequestDecision,
+		notifyModeration,
+	}
+	voteMilestones = []int{10, 50, 100, 500, 1000}
+)
+
+func isNotificationType(t string) bool {
+	for _, known := range notificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+func notificationEnabled(db *pg.DB, userID uuid.UUID, notificationType string) (bool, error) {
+	var pref Models.NotificationPreferences
+	err := db.Model(&pref).
+		Where("user_id = ?", userID).
+		Where("type = ?", notificationType).
+		Select()
+	if err == pg.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return pref.Enabled, nil
+}
+
+func createNotification(db *pg.DB, notification *Models.Notifications) {
+	if notification.ActorID != nil && *notification.ActorID == notification.UserID {
+		return
+	}
+	enabled, err := notificationEnabled(db, notification.UserID, notification.Type)
+	if err != nil {
+		log.Printf("Create Notification Failed (%s for %s): %v", notification.Type, notification.UserID, err)
+		return
+	}
+	if !enabled {
+		return
+	}
+	notification.CreatedAt = time.Now()
+	if _, err := db.Model(notification).Insert(); err != nil {
+		log.Printf("Create Notification Failed (%s for %s): %v", notification.Type, notification.UserID, err)
+		return
+	}
+	publishNotification(notification)
+}
+
+func canNotifyAbout(db *pg.DB, userID uuid.UUID, actorID uuid.UUID, forumID uuid.UUID) (bool, error) {
+	if userID == actorID {
+		return false, nil
+	}
+	blocked, err := isBlockedBetween(db, userID, actorID)
+	if err != nil || blocked {
+		return false, err
+	}
+	ban, err := getActiveForumBan(db, forumID, userID)
+	if err != nil || (ban != nil && ban.Type == "ban") {
+		return false, err
+	}
+	privateForum, err := isPrivateForum(db, forumID)
+	if err != nil || !privateForum {
+		return err == nil, err
+	}
+	isMember, err := db.Model((*Models.ForumMembers)(nil)).
+		Where("forum_id = ?", forumID).
+		Where("user_id = ?", userID).
+		Exists()
+	if err != nil || isMember {
+		return isMember, err
+	}
+	return isSystemAdmin(db, userID)
+}
+
+func notifyReply(db *pg.DB, comment *Models.Comments, forumID uuid.UUID) {
+	var recipient uuid.UUID
+	notificationType := notifyPostReply
+	if comment.ParentCommentID != 0 {
+		var parent Models.Comments
+		if err := db.Model(&parent).Column("user_id").Where("id = ?", comment.ParentCommentID).Select(); err != nil {
+			log.Printf("Notify Reply Failed (comment %d): %v", comment.ID, err)
+			return
+		}
+		recipient, notificationType = parent.UserID, notifyCommentReply
+	} else {
+		var post Models.Posts
+		if err := db.Model(&post).Column("user_id").Where("id = ?", comment.PostID).Select(); err != nil {
+			log.Printf("Notify Reply Failed (comment %d): %v", comment.ID, err)
+			return
+		}
+		recipient = post.UserID
+	}
+
+	allowed, err := canNotifyAbout(db, recipient, comment.UserID, forumID)
+	if err != nil {
+		log.Printf("Notify Reply Failed (comment %d): %v", comment.ID, err)
+		return
+	}
+	if !allowed {
+		return
+	}
+	actorID, postID, commentID := comment.UserID, comment.PostID, comment.ID
+	createNotification(db, &Models.Notifications{
+		UserID:    recipient,
+		ActorID:   &actorID,
+		Type:      notificationType,
+		ForumID:   &forumID,
+		PostID:    &postID,
+		CommentID: &commentID,
+	})
+}
+
+func notifyVoteMilestoneReached(db *pg.DB, forumID uuid.UUID, postID *int, commentID *int) {
+	votes := db.Model((*Models.Votes)(nil)).Where("value = 1")
+	var authorID uuid.UUID
+	var targetPostID int
+	if postID != nil {
+		var post Models.Posts
+		if err := db.Model(&post).Column("user_id").Where("id = ?", *postID).Select(); err != nil {
+			return
+		}
+		authorID, targetPostID = post.UserID, *postID
+		votes.Where("post_id = ?", *postID)
+	} else {
+		var comment Models.Comments
+		if err := db.Model(&comment).Column("user_id", "post_id").Where("id = ?", *commentID).Select(); err != nil {
+			return
+		}
+		authorID, targetPostID = comment.UserID, comment.PostID
+		votes.Where("comment_id = ?", *commentID)
+	}
+
+	upvotes, err := votes.Count()
+	if err != nil {
+		log.Printf("Notify Vote Milestone Failed: %v", err)
+		return
+	}
+	reached := 0
+	for _, m := range voteMilestones {
+		if upvotes == m {
+			reached = m
+		}
+	}
+	if reached == 0 {
+		return
+	}
+
+	sent := db.Model((*Models.Notifications)(nil)).
+		Where("user_id = ?", authorID).
+		Where("type = ?", notifyVoteMilestone).
+		Where("data->>'milestone' = ?", strconv.Itoa(reached))
+	if commentID != nil {
+		sent.Where("comment_id = ?", *commentID)
+	} else {
+		sent.Where("post_id = ? AND comment_id IS NULL", *postID)
+	}
+	exists, err := sent.Exists()
+	if err != nil || exists {
+		return
+	}
+	createNotification(db, &Models.Notifications{
+		UserID:    authorID,
+		Type:      notifyVoteMilestone,
+		ForumID:   &forumID,
+		PostID:    &targetPostID,
+		CommentID: commentID,
+		Data:      gin.H{"milestone": reached},
+	})
+}
+
+func notifyModerationAction(db *pg.DB, actorID uuid.UUID, userID uuid.UUID, forumID uuid.UUID, postID *int, commentID *int, action string, reason string) {
+	createNotification(db, &Models.Notifications{
+		UserID:    userID,
+		ActorID:   &actorID,
+		Type:      notifyModeration,
+		ForumID:   &forumID,
+		PostID:    postID,
+		CommentID: commentID,
+		Data:      gin.H{"action": action, "reason": reason},
+	})
+}
+
+func GetNotifications(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	notifications := make([]Models.Notifications, 0)
+	query := db.Model(&notifications).
+		Relation("Actor.uid").
+		Relation("Actor.username").
+		Where("notifications.user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query.Where("notifications.read_at IS NULL")
+	}
+	if t := c.Query("type"); t != "" {
+		if !isNotificationType(t) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification type"})
+			return
+		}
+		query.Where("notifications.type = ?", t)
+	}
+	err = query.
+		Order("notifications.created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Select()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notifications": notifications})
+}
+
+func GetUnreadNotificationCount(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var counts []struct {
+		Type  string
+		Count int
+	}
+	err = db.Model((*Models.Notifications)(nil)).
+		ColumnExpr("type, COUNT(*) AS count").
+		Where("user_id = ?", userID).
+		Where("read_at IS NULL").
+		Group("type").
+		Select(&counts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	total := 0
+	byType := make(map[string]int, len(counts))
+	for _, row := range counts {
+		byType[row.Type] = row.Count
+		total += row.Count
+	}
+	c.JSON(http.StatusOK, gin.H{"unread": total, "by_type": byType})
+}
+
+func MarkNotificationRead(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	notificationID, err := strconv.Atoi(c.Param("notification_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Notification ID format"})
+		return
+	}
+
+	var notification Models.Notifications
+	err = db.Model(&notification).
+		Where("id = ?", notificationID).
+		Where("user_id = ?", userID).
+		Select()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if notification.ReadAt == nil {
+		now := time.Now()
+		_, err = db.Model(&notification).
+			Set("read_at = ?", now).
+			Where("id = ?", notificationID).
+			Update()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+		notification.ReadAt = &now
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read", "notification": notification})
+}
+
+func MarkAllNotificationsRead(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Model((*Models.Notifications)(nil)).
+		Set("read_at = ?", time.Now()).
+		Where("user_id = ?", userID).
+		Where("read_at IS NULL")
+	if t := c.Query("type"); t != "" {
+		if !isNotificationType(t) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification type"})
+			return
+		}
+		query.Where("type = ?", t)
+	}
+	res, err := query.Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read", "updated": res.RowsAffected()})
+}
+
+func notificationPreferences(db *pg.DB, userID uuid.UUID) (map[string]bool, error) {
+	var prefs []Models.NotificationPreferences
+	if err := db.Model(&prefs).Where("user_id = ?", userID).Select(); err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool, len(notificationTypes))
+	for _, t := range notificationTypes {
+		result[t] = true
+	}
+	for _, p := range prefs {
+		if isNotificationType(p.Type) {
+			result[p.Type] = p.Enabled
+		}
+	}
+	return result, nil
+}
+
+func GetNotificationPreferences(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefs, err := notificationPreferences(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notification preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"preferences": prefs})
+}
+
+func UpdateNotificationPreferences(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var payload map[string]bool
+	if err := c.ShouldBindJSON(&payload); err != nil || len(payload) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	for t := range payload {
+		if !isNotificationType(t) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification type", "detail": t})
+			return
+		}
+	}
+
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		for t, enabled := range payload {
+			pref := &Models.NotificationPreferences{UserID: userID, Type: t, Enabled: enabled}
+			_, err := tx.Model(pref).
+				OnConflict("(user_id, type) DO UPDATE").
+				Set("enabled = EXCLUDED.enabled").
+				Insert()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+		return
+	}
+
+	prefs, err := notificationPreferences(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notification preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notification preferences updated", "preferences": prefs})
+}
@@ -0,0 +1,328 @@
+package Handlers
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/Ariffansyah/UnivTalk/Storage"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	orphanGracePeriod   = 1 * time.Hour
+	quarantineRetention = 7 * 24 * time.Hour
+
+	orphanModeDryRun     = "dry-run"
+	orphanModeQuarantine = "quarantine"
+	orphanModeDelete     = "delete"
+)
+
+type orphanObject struct {
+	Key        string    `json:"key"`
+	SizeBytes  int64     `json:"size_bytes"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+type orphanReport struct {
+	Mode             string         `json:"mode"`
+	StartedAt        time.Time      `json:"started_at"`
+	FinishedAt       time.Time      `json:"finished_at"`
+	Scanned          int            `json:"scanned"`
+	Referenced       int            `json:"referenced"`
+	Orphans          []orphanObject `json:"orphans"`
+	OrphanBytes      int64          `json:"orphan_bytes"`
+	Missing          []string       `json:"missing"`
+	PartialOrphans   []string       `json:"partial_orphans"`
+	Quarantined      int            `json:"quarantined"`
+	Deleted          int            `json:"deleted"`
+	QuarantinePurged int            `json:"quarantine_purged"`
+	Errors           []string       `json:"errors"`
+}
+
+func (r *orphanReport) fail(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func parseOrphanMode(mode string) (string, error) {
+	switch mode {
+	case "", orphanModeDryRun:
+		return orphanModeDryRun, nil
+	case orphanModeQuarantine, orphanModeDelete:
+		return mode, nil
+	}
+	return "", fmt.Errorf("mode must be %q, %q or %q", orphanModeDryRun, orphanModeQuarantine, orphanModeDelete)
+}
+
+func referencedMediaKeys(db *pg.DB) (map[string]bool, error) {
+	var keys []string
+	_, err := db.Query(&keys, `
+		SELECT storage_key FROM post_attachments WHERE storage_key <> ''
+		UNION
+		SELECT storage_key FROM attachment_variants WHERE storage_key <> ''
+		UNION
+		SELECT storage_key FROM message_attachments WHERE storage_key <> ''
+	`)
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		referenced[key] = true
+	}
+	return referenced, nil
+}
+
+func sweepOrphanedMedia(ctx context.Context, db *pg.DB, mode string) (*orphanReport, error) {
+	report := &orphanReport{
+		Mode:           mode,
+		StartedAt:      time.Now(),
+		Orphans:        []orphanObject{},
+		Missing:        []string{},
+		PartialOrphans: []string{},
+		Errors:         []string{},
+	}
+
+	referenced, err := referencedMediaKeys(db)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := report.StartedAt.Add(-orphanGracePeriod)
+	purgeBefore := report.StartedAt.Add(-quarantineRetention)
+	found := make(map[string]bool, len(referenced))
+	expired := make([]string, 0)
+	err = mediaStorage.List(ctx, func(obj Storage.Object) error {
+		if strings.HasPrefix(obj.Key, Storage.QuarantinePrefix) {
+			if obj.ModTime.Before(purgeBefore) {
+				expired = append(expired, obj.Key)
+			}
+			return nil
+		}
+		report.Scanned++
+		if referenced[obj.Key] {
+			found[obj.Key] = true
+			report.Referenced++
+			return nil
+		}
+		if obj.ModTime.After(cutoff) {
+			return nil
+		}
+		report.Orphans = append(report.Orphans, orphanObject{Key: obj.Key, SizeBytes: obj.Size, ModifiedAt: obj.ModTime})
+		report.OrphanBytes += obj.Size
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for key := range referenced {
+		if !found[key] {
+			report.Missing = append(report.Missing, key)
+		}
+	}
+	sort.Strings(report.Missing)
+
+	if err := findPartialOrphans(db, cutoff, report); err != nil {
+		report.fail("partial uploads: %v", err)
+	}
+
+	if mode != orphanModeDryRun {
+		for _, obj := range report.Orphans {
+			if mode == orphanModeQuarantine {
+				if err := quarantineMediaObject(ctx, obj); err != nil {
+					report.fail("quarantine %s: %v", obj.Key, err)
+					continue
+				}
+				report.Quarantined++
+				continue
+			}
+			if err := mediaStorage.Delete(ctx, obj.Key); err != nil {
+				report.fail("delete %s: %v", obj.Key, err)
+				continue
+			}
+			report.Deleted++
+		}
+		for _, key := range expired {
+			if err := mediaStorage.Delete(ctx, key); err != nil {
+				report.fail("purge %s: %v", key, err)
+				continue
+			}
+			report.QuarantinePurged++
+		}
+		for _, name := range report.PartialOrphans {
+			if err := os.Remove(filepath.Join(partialUploadDir(), name)); err != nil && !os.IsNotExist(err) {
+				report.fail("remove partial upload %s: %v", name, err)
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+func quarantineMediaObject(ctx context.Context, obj orphanObject) error {
+	src, err := mediaStorage.Get(ctx, obj.Key)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := mediaStorage.Put(ctx, Storage.QuarantinePrefix+obj.Key, src, obj.SizeBytes, "application/octet-stream"); err != nil {
+		return err
+	}
+	return mediaStorage.Delete(ctx, obj.Key)
+}
+
+func findPartialOrphans(db *pg.DB, cutoff time.Time, report *orphanReport) error {
+	entries, err := os.ReadDir(partialUploadDir())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var ids []uuid.UUID
+	if err := db.Model((*Models.MediaUploads)(nil)).Column("id").Select(&ids); err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		known[id.String()+".part"] = true
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || known[entry.Name()] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		report.PartialOrphans = append(report.PartialOrphans, entry.Name())
+	}
+	return nil
+}
+
+func logOrphanReport(report *orphanReport) {
+	log.Printf("Orphan Sweep (%s): scanned %d, orphans %d (%d bytes), quarantined %d, deleted %d, quarantine purged %d, missing %d, partial %d",
+		report.Mode, report.Scanned, len(report.Orphans), report.OrphanBytes, report.Quarantined, report.Deleted,
+		report.QuarantinePurged, len(report.Missing), len(report.PartialOrphans))
+	for _, e := range report.Errors {
+		log.Printf("Orphan Sweep Failed: %s", e)
+	}
+}
+
+func StartOrphanSweepJob(db *pg.DB, interval time.Duration) {
+	mode := os.Getenv("ORPHAN_SWEEP_MODE")
+	if mode == "off" {
+		return
+	}
+	// Moving or deleting files must be opted into; by default the sweeper
+	// only reports what it would do.
+	if mode == "" {
+		mode = orphanModeDryRun
+	}
+	mode, err := parseOrphanMode(mode)
+	if err != nil {
+		log.Printf("Orphan Sweep Job Disabled: ORPHAN_SWEEP_MODE %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report, err := sweepOrphanedMedia(context.Background(), db, mode)
+		if err != nil {
+			log.Printf("Orphan Sweep Job Failed: %v", err)
+			continue
+		}
+		logOrphanReport(report)
+	}
+}
+
+func RunOrphanSweepCommand(db *pg.DB, args []string) int {
+	flags := flag.NewFlagSet("gc-uploads", flag.ContinueOnError)
+	mode := flags.String("mode", orphanModeDryRun, "dry-run, quarantine or delete")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	parsed, err := parseOrphanMode(*mode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	report, err := sweepOrphanedMedia(context.Background(), db, parsed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Orphan sweep failed:", err)
+		return 1
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+	if len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func SweepOrphanedMedia(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	actorID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	isSysAdmin, err := isSystemAdmin(db, actorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !isSysAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "detail": "Only system admins can clean up storage"})
+		return
+	}
+
+	mode, err := parseOrphanMode(c.Query("mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode", "detail": err.Error()})
+		return
+	}
+
+	report, err := sweepOrphanedMedia(c.Request.Context(), db, mode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan storage", "detail": err.Error()})
+		return
+	}
+	logOrphanReport(report)
+
+	if mode != orphanModeDryRun {
+		recordAuditLog(db, &Models.AuditLogs{
+			ActorID:    actorID,
+			Action:     "storage.gc",
+			TargetType: "storage",
+			TargetID:   mode,
+			After: gin.H{
+				"orphans":           len(report.Orphans),
+				"orphan_bytes":      report.OrphanBytes,
+				"quarantined":       report.Quarantined,
+				"deleted":           report.Deleted,
+				"quarantine_purged": report.QuarantinePurged,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
@@ -0,0 +1,403 @@
+package Handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	minPollOptions = 2
+	maxPollOptions = 10
+)
+
+var errAlreadyVoted = fmt.Errorf("already voted")
+
+func parsePollInput(raw string) (*Models.Polls, error) {
+	var input struct {
+		Question       string     `json:"question"`
+		Options        []string   `json:"options"`
+		MultipleChoice bool       `json:"multiple_choice"`
+		Anonymous      bool       `json:"anonymous"`
+		ClosesAt       *time.Time `json:"closes_at"`
+	}
+	if err := json.Unmarshal([]byte(raw), &input); err != nil {
+		return nil, fmt.Errorf("poll must be valid JSON")
+	}
+
+	question := strings.TrimSpace(input.Question)
+	if question == "" {
+		return nil, fmt.Errorf("poll question is required")
+	}
+	if len(question) > 300 {
+		return nil, fmt.Errorf("poll question must be at most 300 characters")
+	}
+	if len(input.Options) < minPollOptions || len(input.Options) > maxPollOptions {
+		return nil, fmt.Errorf("poll must have between %d and %d options", minPollOptions, maxPollOptions)
+	}
+	if input.ClosesAt != nil && !input.ClosesAt.After(time.Now()) {
+		return nil, fmt.Errorf("poll closing time must be in the future")
+	}
+
+	poll := &Models.Polls{
+		Question:       question,
+		MultipleChoice: input.MultipleChoice,
+		IsAnonymous:    input.Anonymous,
+		ClosesAt:       input.ClosesAt,
+		CreatedAt:      time.Now(),
+	}
+	seen := make(map[string]bool, len(input.Options))
+	for i, text := range input.Options {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return nil, fmt.Errorf("poll options must not be empty")
+		}
+		if len(text) > 200 {
+			return nil, fmt.Errorf("poll options must be at most 200 characters")
+		}
+		if seen[strings.ToLower(text)] {
+			return nil, fmt.Errorf("poll options must be unique")
+		}
+		seen[strings.ToLower(text)] = true
+		poll.Options = append(poll.Options, Models.PollOptions{Position: i + 1, Text: text})
+	}
+	return poll, nil
+}
+
+func createPoll(tx *pg.Tx, postID int, poll *Models.Polls) error {
+	poll.PostID = postID
+	if _, err := tx.Model(poll).Insert(); err != nil {
+		return err
+	}
+	for i := range poll.Options {
+		poll.Options[i].PollID = poll.ID
+	}
+	_, err := tx.Model(&poll.Options).Insert()
+	return err
+}
+
+func loadPolls(db *pg.DB, postIDs []int, currentUser uuid.UUID) (map[int]*Models.Polls, error) {
+	result := make(map[int]*Models.Polls)
+	if len(postIDs) == 0 {
+		return result, nil
+	}
+
+	var polls []Models.Polls
+	err := db.Model(&polls).
+		Relation("Options").
+		Where("post_id IN (?)", pg.In(postIDs)).
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	if len(polls) == 0 {
+		return result, nil
+	}
+
+	pollIDs := make([]int, 0, len(polls))
+	for _, p := range polls {
+		pollIDs = append(pollIDs, p.ID)
+	}
+
+	type OptionCount struct {
+		OptionID int
+		Votes    int
+	}
+	var optionCounts []OptionCount
+	_, err = db.Query(&optionCounts, `
+		SELECT option_id, COUNT(*) AS votes
+		FROM poll_votes
+		WHERE poll_id IN ( ? )
+		GROUP BY option_id
+	`, pg.In(pollIDs))
+	if err != nil {
+		return nil, err
+	}
+	optionCountMap := make(map[int]int, len(optionCounts))
+	for _, oc := range optionCounts {
+		optionCountMap[oc.OptionID] = oc.Votes
+	}
+
+	type VoterCount struct {
+		PollID int
+		Voters int
+	}
+	var voterCounts []VoterCount
+	_, err = db.Query(&voterCounts, `
+		SELECT poll_id, COUNT(DISTINCT user_id) AS voters
+		FROM poll_votes
+		WHERE poll_id IN ( ? )
+		GROUP BY poll_id
+	`, pg.In(pollIDs))
+	if err != nil {
+		return nil, err
+	}
+	voterCountMap := make(map[int]int, len(voterCounts))
+	for _, vc := range voterCounts {
+		voterCountMap[vc.PollID] = vc.Voters
+	}
+
+	myVoteMap := make(map[int][]int)
+	if currentUser != uuid.Nil {
+		var myVotes []Models.PollVotes
+		err = db.Model(&myVotes).
+			Where("user_id = ?", currentUser).
+			Where("poll_id IN (?)", pg.In(pollIDs)).
+			Select()
+		if err != nil {
+			return nil, err
+		}
+		for _, mv := range myVotes {
+			myVoteMap[mv.PollID] = append(myVoteMap[mv.PollID], mv.OptionID)
+		}
+	}
+
+	now := time.Now()
+	for i := range polls {
+		p := &polls[i]
+		sort.Slice(p.Options, func(a, b int) bool { return p.Options[a].Position < p.Options[b].Position })
+		for j := range p.Options {
+			p.Options[j].Votes = optionCountMap[p.Options[j].ID]
+		}
+		p.IsClosed = p.ClosesAt != nil && !p.ClosesAt.After(now)
+		p.TotalVoters = voterCountMap[p.ID]
+		p.MyVotes = myVoteMap[p.ID]
+		if p.MyVotes == nil {
+			p.MyVotes = []int{}
+		}
+		result[p.PostID] = p
+	}
+	return result, nil
+}
+
+func attachPolls(db *pg.DB, posts []Models.PostWithCounts, currentUser uuid.UUID) {
+	postIDs := make([]int, 0, len(posts))
+	for _, p := range posts {
+		postIDs = append(postIDs, p.ID)
+	}
+	polls, err := loadPolls(db, postIDs, currentUser)
+	if err != nil {
+		return
+	}
+	for i := range posts {
+		posts[i].Poll = polls[posts[i].ID]
+	}
+}
+
+func loadPollForPost(c *gin.Context, db *pg.DB) (int, *Models.Polls, uuid.UUID, bool) {
+	postID, err := strconv.Atoi(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID format"})
+		return 0, nil, uuid.Nil, false
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, nil, uuid.Nil, false
+	}
+
+	forumID, err := getForumIDByPostID(db, postID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return 0, nil, uuid.Nil, false
+	}
+	if rejectPrivateForum(c, db, userID, forumID) {
+		return 0, nil, uuid.Nil, false
+	}
+
+	polls, err := loadPolls(db, []int{postID}, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll"})
+		return 0, nil, uuid.Nil, false
+	}
+	poll, ok := polls[postID]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post has no poll"})
+		return 0, nil, uuid.Nil, false
+	}
+	return postID, poll, userID, true
+}
+
+func GetPoll(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	_, poll, _, ok := loadPollForPost(c, db)
+	if !ok {
+		return
+	}
+
+	if !poll.IsAnonymous {
+		type VoterRow struct {
+			OptionID int
+			Username string
+		}
+		var voters []VoterRow
+		_, err := db.Query(&voters, `
+			SELECT pv.option_id, u.username
+			FROM poll_votes pv
+			JOIN users u ON u.uid = pv.user_id
+			WHERE pv.poll_id = ?
+			ORDER BY pv.created_at ASC
+		`, poll.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve poll voters"})
+			return
+		}
+		votersByOption := make(map[int][]string)
+		for _, v := range voters {
+			votersByOption[v.OptionID] = append(votersByOption[v.OptionID], v.Username)
+		}
+		for i := range poll.Options {
+			poll.Options[i].Voters = votersByOption[poll.Options[i].ID]
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"poll": poll})
+}
+
+func VotePoll(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	postID, poll, userID, ok := loadPollForPost(c, db)
+	if !ok {
+		return
+	}
+
+	targetPost, err := getVoteTargetPost(db, &postID, nil)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if rejectLockedPost(c, targetPost, "Voting is not allowed on this post") {
+		return
+	}
+	ban, err := getActiveForumBan(db, targetPost.ForumID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if ban != nil {
+		c.JSON(http.StatusForbidden, forumBanResponse(ban))
+		return
+	}
+
+	if poll.IsClosed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Poll is closed"})
+		return
+	}
+
+	var payload struct {
+		OptionIDs []int `json:"option_ids"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+	if len(payload.OptionIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one option is required"})
+		return
+	}
+	if !poll.MultipleChoice && len(payload.OptionIDs) > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This poll allows only one choice"})
+		return
+	}
+
+	validOptions := make(map[int]bool, len(poll.Options))
+	for _, o := range poll.Options {
+		validOptions[o.ID] = true
+	}
+	chosen := make(map[int]bool, len(payload.OptionIDs))
+	votes := make([]Models.PollVotes, 0, len(payload.OptionIDs))
+	now := time.Now()
+	for _, optionID := range payload.OptionIDs {
+		if !validOptions[optionID] || chosen[optionID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid poll option"})
+			return
+		}
+		chosen[optionID] = true
+		votes = append(votes, Models.PollVotes{
+			PollID:    poll.ID,
+			OptionID:  optionID,
+			UserID:    userID,
+			CreatedAt: now,
+		})
+	}
+
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		var locked Models.Polls
+		if err := tx.Model(&locked).Where("id = ?", poll.ID).For("UPDATE").Select(); err != nil {
+			return err
+		}
+		voted, err := tx.Model((*Models.PollVotes)(nil)).
+			Where("poll_id = ? AND user_id = ?", poll.ID, userID).
+			Exists()
+		if err != nil {
+			return err
+		}
+		if voted {
+			return errAlreadyVoted
+		}
+		_, err = tx.Model(&votes).Insert()
+		return err
+	})
+	if err == errAlreadyVoted {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already voted in this poll"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cast poll vote"})
+		return
+	}
+
+	ch.Delete(fmt.Sprintf("post_%d", postID))
+	c.JSON(http.StatusOK, gin.H{"message": "Poll vote recorded", "option_ids": payload.OptionIDs})
+}
+
+func ClosePoll(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	postID, poll, userID, ok := loadPollForPost(c, db)
+	if !ok {
+		return
+	}
+
+	var post Models.Posts
+	if err := db.Model(&post).Column("user_id", "forum_id").Where("id = ?", postID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if post.UserID != userID {
+		hasAccess, err := canModerateForum(db, userID, post.ForumID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+			return
+		}
+		if !hasAccess {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+	}
+
+	if poll.IsClosed {
+		c.JSON(http.StatusOK, gin.H{"message": "No change", "closes_at": poll.ClosesAt})
+		return
+	}
+
+	now := time.Now()
+	_, err := db.Model((*Models.Polls)(nil)).
+		Set("closes_at = ?", now).
+		Where("id = ?", poll.ID).
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close poll"})
+		return
+	}
+
+	ch.Delete(fmt.Sprintf("post_%d", postID))
+	c.JSON(http.StatusOK, gin.H{"message": "Poll closed", "closes_at": now})
+}
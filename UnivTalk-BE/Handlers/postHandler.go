@@ -1,9 +1,10 @@
 package Handlers
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -29,10 +30,23 @@ func GetForumPosts(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		currentUser = uid
 	}
 
+	if rejectPrivateForum(c, db, currentUser, forumID) {
+		return
+	}
+
 	var posts []Models.Posts
-	err = db.Model(&posts).
+	query := db.Model(&posts).
 		Relation("User").
-		Where("forum_id = ?", forumID).
+		Where("forum_id = ?", forumID)
+	if rawFlairID := c.Query("flair_id"); rawFlairID != "" {
+		flairID, err := strconv.Atoi(rawFlairID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Flair ID format"})
+			return
+		}
+		query.Where("flair_id = ?", flairID)
+	}
+	err = query.
 		Order("posts.created_at DESC").
 		Select()
 	if err != nil {
@@ -110,6 +124,14 @@ func GetForumPosts(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		}
 	}
 
+	sortPinnedFirst(response)
+
+	attachPolls(db, response, currentUser)
+	attachMedia(db, response, currentUser)
+	attachSaved(db, response, currentUser)
+	attachTags(db, response)
+	attachFlairs(db, response)
+
 	c.JSON(http.StatusOK, gin.H{"posts": response})
 }
 
@@ -136,6 +158,9 @@ func GetForumPostsByID(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve post"})
 		return
 	}
+	if rejectPrivateForum(c, db, currentUser, post.ForumID) {
+		return
+	}
 
 	type VoteCount struct {
 		Up   int
@@ -168,29 +193,78 @@ func GetForumPostsByID(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		}
 	}
 
+	var poll *Models.Polls
+	if polls, err := loadPolls(db, []int{post.ID}, currentUser); err == nil {
+		poll = polls[post.ID]
+	}
+
+	var flair *Models.ForumFlairs
+	if post.FlairID != nil {
+		if loaded, err := loadFlairs(db, []int{*post.FlairID}); err == nil {
+			flair = loaded[*post.FlairID]
+		}
+	}
+
+	tags := []string{}
+	if loaded, err := loadPostTags(db, []int{post.ID}, ""); err == nil && loaded[post.ID] != nil {
+		tags = loaded[post.ID]
+	}
+
+	saved := false
+	if currentUser != uuid.Nil {
+		saved, _ = isPostSaved(db, currentUser, post.ID)
+	}
+
+	attachments := []Models.PostAttachments{}
+	if loaded, err := loadAttachments(db, []int{post.ID}); err == nil && loaded[post.ID] != nil {
+		attachments = loaded[post.ID]
+		access := privateMediaAccess(db, currentUser, []uuid.UUID{post.ForumID})
+		resolveAttachmentURLs(attachments, access[post.ForumID])
+		post.MediaURL = attachments[0].URL
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"post": gin.H{
-			"id":         post.ID,
-			"forum_id":   post.ForumID,
-			"user_id":    post.UserID,
-			"title":      post.Title,
-			"body":       post.Body,
-			"media_url":  post.MediaURL,
-			"media_type": post.MediaType,
-			"created_at": post.CreatedAt,
-			"updated_at": post.UpdatedAt,
-			"user":       post.User,
-			"upvotes":    counts.Up,
-			"downvotes":  counts.Down,
-			"my_vote":    myVotePtr,
+			"id":              post.ID,
+			"forum_id":        post.ForumID,
+			"user_id":         post.UserID,
+			"title":           post.Title,
+			"body":            post.Body,
+			"body_html":       post.BodyHTML,
+			"media_url":       post.MediaURL,
+			"media_type":      post.MediaType,
+			"is_locked":       post.IsLocked,
+			"pin_position":    post.PinPosition,
+			"is_announcement": post.IsAnnouncement,
+			"flair_id":        post.FlairID,
+			"flair":           flair,
+			"created_at":      post.CreatedAt,
+			"updated_at":      post.UpdatedAt,
+			"edited_at":       post.EditedAt,
+			"user":            post.User,
+			"upvotes":         counts.Up,
+			"downvotes":       counts.Down,
+			"my_vote":         myVotePtr,
+			"poll":            poll,
+			"attachments":     attachments,
+			"saved":           saved,
+			"tags":            tags,
 		},
 	})
 }
 
 func GetGlobalPosts(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userIDInterface, _ := c.Get("user_id")
+	var currentUser uuid.UUID
+	if uid, ok := userIDInterface.(uuid.UUID); ok {
+		currentUser = uid
+	}
+
+	visible, visibleArgs := visiblePostsCondition(db, currentUser)
 	var posts []Models.Posts
 	err := db.Model(&posts).
 		Relation("User").
+		Where(visible, visibleArgs...).
 		Order("posts.created_at DESC").
 		Select()
 	if err != nil {
@@ -225,12 +299,6 @@ func GetGlobalPosts(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		countMap[cRow.PostID] = cRow
 	}
 
-	userIDInterface, _ := c.Get("user_id")
-	var currentUser uuid.UUID
-	if uid, ok := userIDInterface.(uuid.UUID); ok {
-		currentUser = uid
-	}
-
 	type MyVoteRow struct {
 		PostID int
 		Value  int
@@ -274,6 +342,14 @@ func GetGlobalPosts(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		}
 	}
 
+	sortAnnouncementsFirst(response)
+
+	attachPolls(db, response, currentUser)
+	attachMedia(db, response, currentUser)
+	attachSaved(db, response, currentUser)
+	attachTags(db, response)
+	attachFlairs(db, response)
+
 	c.JSON(http.StatusOK, gin.H{"posts": response})
 }
 
@@ -285,10 +361,18 @@ func GetPostByUserID(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
+	userIDInterface, _ := c.Get("user_id")
+	var currentUser uuid.UUID
+	if uid, ok := userIDInterface.(uuid.UUID); ok {
+		currentUser = uid
+	}
+
+	visible, visibleArgs := visiblePostsCondition(db, currentUser)
 	var posts []Models.Posts
 	err = db.Model(&posts).
 		Relation("User").
-		Where("user_id = ?", userID).
+		Where("posts.user_id = ?", userID).
+		Where(visible, visibleArgs...).
 		Order("posts.created_at DESC").
 		Select()
 	if err != nil {
@@ -323,12 +407,6 @@ func GetPostByUserID(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		countMap[cRow.PostID] = cRow
 	}
 
-	userIDInterface, _ := c.Get("user_id")
-	var currentUser uuid.UUID
-	if uid, ok := userIDInterface.(uuid.UUID); ok {
-		currentUser = uid
-	}
-
 	type MyVoteRow struct {
 		PostID int
 		Value  int
@@ -372,9 +450,104 @@ func GetPostByUserID(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		}
 	}
 
+	attachPolls(db, response, currentUser)
+	attachMedia(db, response, currentUser)
+	attachSaved(db, response, currentUser)
+	attachTags(db, response)
+	attachFlairs(db, response)
+
 	c.JSON(http.StatusOK, gin.H{"posts": response})
 }
 
+func loadPostsWithCounts(db *pg.DB, postIDs []int, currentUser uuid.UUID) ([]Models.PostWithCounts, error) {
+	response := make([]Models.PostWithCounts, 0, len(postIDs))
+	if len(postIDs) == 0 {
+		return response, nil
+	}
+
+	var posts []Models.Posts
+	err := db.Model(&posts).
+		Relation("User").
+		Where("posts.id IN (?)", pg.In(postIDs)).
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	postMap := make(map[int]Models.Posts, len(posts))
+	for _, p := range posts {
+		postMap[p.ID] = p
+	}
+
+	type VoteCount struct {
+		PostID int
+		Up     int
+		Down   int
+	}
+	var counts []VoteCount
+	_, err = db.Query(&counts, `
+		SELECT post_id,
+		       COALESCE(SUM(CASE WHEN value = 1 THEN 1 ELSE 0 END), 0) AS up,
+		       COALESCE(SUM(CASE WHEN value = -1 THEN 1 ELSE 0 END), 0) AS down
+		FROM votes
+		WHERE post_id IN ( ? )
+		GROUP BY post_id
+	`, pg.In(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	countMap := make(map[int]VoteCount, len(counts))
+	for _, cRow := range counts {
+		countMap[cRow.PostID] = cRow
+	}
+
+	type MyVoteRow struct {
+		PostID int
+		Value  int
+	}
+	myVoteMap := make(map[int]int)
+	if currentUser != uuid.Nil {
+		var myVotes []MyVoteRow
+		_, err = db.Query(&myVotes, `
+			SELECT post_id, value
+			FROM votes
+			WHERE user_id = ? AND post_id IN ( ? )
+		`, currentUser, pg.In(postIDs))
+		if err != nil {
+			return nil, err
+		}
+		for _, mv := range myVotes {
+			myVoteMap[mv.PostID] = mv.Value
+		}
+	}
+
+	for _, id := range postIDs {
+		p, ok := postMap[id]
+		if !ok {
+			continue
+		}
+		count := countMap[p.ID]
+		var mvPtr *int
+		if v, ok := myVoteMap[p.ID]; ok {
+			mvPtr = new(int)
+			*mvPtr = v
+		}
+		response = append(response, Models.PostWithCounts{
+			Posts:     p,
+			Upvotes:   count.Up,
+			Downvotes: count.Down,
+			MyVote:    mvPtr,
+		})
+	}
+
+	attachPolls(db, response, currentUser)
+	attachMedia(db, response, currentUser)
+	attachSaved(db, response, currentUser)
+	attachTags(db, response)
+	attachFlairs(db, response)
+
+	return response, nil
+}
+
 func CreatePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -395,6 +568,16 @@ func CreatePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
+	ban, err := getActiveForumBan(db, forumID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum access"})
+		return
+	}
+	if ban != nil {
+		c.JSON(http.StatusForbidden, forumBanResponse(ban))
+		return
+	}
+
 	post := Models.Posts{
 		Title:     c.PostForm("title"),
 		Body:      c.PostForm("body"),
@@ -408,39 +591,148 @@ func CreatePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Title and Body are required"})
 		return
 	}
+	post.BodyHTML = renderMarkdown(post.Body)
 
-	file, err := c.FormFile("media")
-	if err == nil {
-		ext := strings.ToLower(filepath.Ext(file.Filename))
-		allowedImages := map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true}
-		allowedVideos := map[string]bool{".mp4": true, ".mov": true, ".avi": true, ".mkv": true}
-
-		if allowedImages[ext] {
-			post.MediaType = "image"
-		} else if allowedVideos[ext] {
-			post.MediaType = "video"
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported file type"})
+	var poll *Models.Polls
+	if rawPoll := strings.TrimSpace(c.PostForm("poll")); rawPoll != "" {
+		poll, err = parsePollInput(rawPoll)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid poll", "detail": err.Error()})
 			return
 		}
+	}
 
-		newFileName := uuid.New().String() + ext
-		uploadPath := "./uploads/" + newFileName
+	privateForum, err := isPrivateForum(db, forumID)
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forum not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve forum"})
+		return
+	}
+	if privateForum && rejectPrivateForum(c, db, userID, forumID) {
+		return
+	}
 
-		if err := c.SaveUploadedFile(file, uploadPath); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-			return
-		}
-		post.MediaURL = "/uploads/" + newFileName
+	flairID, err := parseFlairID(c.PostForm("flair_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flair", "detail": err.Error()})
+		return
 	}
+	post.Flair, err = resolvePostFlair(db, forumID, userID, nil, flairID)
+	if err != nil {
+		flairErrorResponse(c, err)
+		return
+	}
+	post.FlairID = flairID
 
-	_, err = db.Model(&post).Insert()
+	tagInput, err := parseTagInput(c.PostFormArray("tags"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tags", "detail": err.Error()})
+		return
+	}
+	tags, err := resolvePostTags(db, forumID, tagInput, true, post.Body)
+	if rejected, ok := err.(*tagRejectedError); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tags", "detail": rejected.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve tags"})
+		return
+	}
+
+	files := uploadedMediaFiles(c)
+	uploads, err := loadCompletedUploads(db, userID, uploadIDsFromRequest(c))
+	if err == nil {
+		err = validateUploadedMedia(c, files, len(uploads))
+	}
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media", "detail": err.Error()})
+		return
+	}
+	incoming, reserved := incomingMediaSize(files, uploads)
+	err = checkStorageQuota(db, userID, forumID, incoming, reserved)
+	if exceeded, ok := err.(*quotaExceededError); ok {
+		c.JSON(http.StatusRequestEntityTooLarge, quotaExceededResponse(exceeded))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+		return
+	}
+	attachments, err := saveUploadedAttachments(c, files, uploads, privateForum)
+	if rejected, ok := err.(*mediaRejectedError); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media", "detail": rejected.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return
+	}
+	for i := range attachments {
+		attachments[i].Position = i + 1
+	}
+	if len(attachments) > 0 {
+		post.MediaURL = attachments[0].URL
+		post.MediaType = attachments[0].MediaType
+	}
+
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		if err := checkStorageQuotaLocked(tx, userID, forumID, incoming, reserved); err != nil {
+			return err
+		}
+		if _, err := tx.Model(&post).Insert(); err != nil {
+			return err
+		}
+		if err := insertAttachments(tx, post.ID, attachments); err != nil {
+			return err
+		}
+		if err := consumeUploads(tx, uploads); err != nil {
+			return err
+		}
+		post.Attachments = attachments
+		names, err := syncPostTags(tx, post.ID, tags)
+		if err != nil {
+			return err
+		}
+		post.Tags = names
+		if poll == nil {
+			return nil
+		}
+		if err := createPoll(tx, post.ID, poll); err != nil {
+			return err
+		}
+		poll.MyVotes = []int{}
+		post.Poll = poll
+		return nil
+	})
+	if err == errUploadConsumed {
+		removeAttachmentFiles(attachments)
+		c.JSON(http.StatusConflict, gin.H{"error": "Invalid media", "detail": err.Error()})
+		return
+	}
+	if exceeded, ok := err.(*quotaExceededError); ok {
+		removeAttachmentFiles(attachments)
+		c.JSON(http.StatusRequestEntityTooLarge, quotaExceededResponse(exceeded))
+		return
+	}
+	if err != nil {
+		removeAttachmentFiles(attachments)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post", "detail": err.Error()})
 		return
 	}
 
 	ch.Delete(fmt.Sprintf("posts_forum_%s", forumID.String()))
+	removeConsumedUploads(uploads)
+	syncMentions(db, userID, forumID, post.ID, nil, post.Title+"\n"+post.Body)
+	queueAttachmentVariants(attachments)
+	resolveAttachmentURLs(post.Attachments, true)
+	if len(post.Attachments) > 0 {
+		post.MediaURL = post.Attachments[0].URL
+	}
+	publishPostCreated(&post)
+	enqueueWebhookEvent(db, forumID, webhookPostCreated, post)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Post created successfully",
@@ -474,28 +766,227 @@ func UpdatePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
-	var updateData Models.Posts
-	if err := c.ShouldBindJSON(&updateData); err != nil {
+	var updateData struct {
+		Title               string           `json:"title" form:"title"`
+		Body                string           `json:"body" form:"body"`
+		RemoveAttachmentIDs []int            `json:"remove_attachment_ids" form:"remove_attachment_ids"`
+		UploadIDs           []string         `json:"upload_ids" form:"-"`
+		Attachments         []attachmentMeta `json:"attachments" form:"-"`
+		Tags                []string         `json:"tags" form:"-"`
+		FlairID             *int             `json:"flair_id" form:"-"`
+	}
+	if err := c.ShouldBind(&updateData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		meta, err := parseAttachmentMeta(c.PostForm("attachments"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+			return
+		}
+		updateData.Attachments = meta
+		updateData.UploadIDs = uploadIDsFromRequest(c)
+		updateData.Tags, _ = c.GetPostFormArray("tags")
+		if rawFlairID, ok := c.GetPostForm("flair_id"); ok {
+			flairID, err := parseFlairID(rawFlairID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flair", "detail": err.Error()})
+				return
+			}
+			if flairID == nil {
+				flairID = new(int)
+			}
+			updateData.FlairID = flairID
+		}
+	}
+	tagsProvided := updateData.Tags != nil
 
-	res, err := db.Model(&existingPost).
-		Set("title = ?", updateData.Title).
-		Set("body = ?", updateData.Body).
-		Set("updated_at = ?", time.Now()).
-		Where("id = ?", postID).
-		Update()
+	flairChanged := updateData.FlairID != nil
+	var newFlairID *int
+	var flair *Models.ForumFlairs
+	if flairChanged {
+		if *updateData.FlairID != 0 {
+			newFlairID = updateData.FlairID
+		}
+		flair, err = resolvePostFlair(db, existingPost.ForumID, userID, existingPost.FlairID, newFlairID)
+		if err != nil {
+			flairErrorResponse(c, err)
+			return
+		}
+	}
+	if updateData.Title == "" {
+		updateData.Title = existingPost.Title
+	}
+	if updateData.Body == "" {
+		updateData.Body = existingPost.Body
+	}
+
+	var tagInput []string
+	if tagsProvided {
+		tagInput, err = parseTagInput(updateData.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tags", "detail": err.Error()})
+			return
+		}
+	} else {
+		existingTags, err := loadPostTags(db, []int{postID}, "manual")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+			return
+		}
+		tagInput = existingTags[postID]
+	}
+	tags, err := resolvePostTags(db, existingPost.ForumID, tagInput, tagsProvided, updateData.Body)
+	if rejected, ok := err.(*tagRejectedError); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tags", "detail": rejected.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve tags"})
+		return
+	}
+	tagsChanged := tagsProvided || updateData.Body != existingPost.Body
+
+	files := uploadedMediaFiles(c)
+	mediaChanged := len(files) > 0 || len(updateData.UploadIDs) > 0 || len(updateData.RemoveAttachmentIDs) > 0 || len(updateData.Attachments) > 0
 
-	if err != nil || res.RowsAffected() == 0 {
+	var removedAttachments, keptAttachments, addedAttachments []Models.PostAttachments
+	var uploads []Models.MediaUploads
+	var incoming, reserved int64
+	if mediaChanged {
+		existing, err := loadAttachments(db, []int{postID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attachments"})
+			return
+		}
+		uploads, err = loadCompletedUploads(db, userID, updateData.UploadIDs)
+		if err == nil {
+			keptAttachments, err = planAttachmentChanges(existing[postID], updateData.RemoveAttachmentIDs, updateData.Attachments, len(files)+len(uploads))
+		}
+		if err == nil {
+			err = validateUploadedMedia(c, files, len(uploads))
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media", "detail": err.Error()})
+			return
+		}
+		incoming, reserved = incomingMediaSize(files, uploads)
+		err = checkStorageQuota(db, userID, existingPost.ForumID, incoming, reserved)
+		if exceeded, ok := err.(*quotaExceededError); ok {
+			c.JSON(http.StatusRequestEntityTooLarge, quotaExceededResponse(exceeded))
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+			return
+		}
+		for _, a := range existing[postID] {
+			for _, id := range updateData.RemoveAttachmentIDs {
+				if a.ID == id {
+					removedAttachments = append(removedAttachments, a)
+				}
+			}
+		}
+		privateForum, err := isPrivateForum(db, existingPost.ForumID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve forum"})
+			return
+		}
+		addedAttachments, err = saveUploadedAttachments(c, files, uploads, privateForum)
+		if rejected, ok := err.(*mediaRejectedError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media", "detail": rejected.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+			return
+		}
+	}
+
+	var tagNames []string
+	now := time.Now()
+	contentChanged := updateData.Title != existingPost.Title || updateData.Body != existingPost.Body
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		if err := checkStorageQuotaLocked(tx, userID, existingPost.ForumID, incoming, reserved); err != nil {
+			return err
+		}
+		update := tx.Model(&existingPost).
+			Set("title = ?", updateData.Title).
+			Set("body = ?", updateData.Body).
+			Set("body_html = ?", renderMarkdown(updateData.Body)).
+			Set("updated_at = ?", now).
+			Where("id = ?", postID)
+		if contentChanged {
+			update.Set("edited_at = ?", now)
+		}
+		if flairChanged {
+			update.Set("flair_id = ?", newFlairID)
+		}
+		res, err := update.Update()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+		if mediaChanged {
+			if err := applyAttachmentChanges(tx, postID, keptAttachments, updateData.RemoveAttachmentIDs, addedAttachments); err != nil {
+				return err
+			}
+			if err := consumeUploads(tx, uploads); err != nil {
+				return err
+			}
+		}
+		if tagsChanged {
+			if tagNames, err = syncPostTags(tx, postID, tags); err != nil {
+				return err
+			}
+		}
+		if !contentChanged {
+			return nil
+		}
+		return savePostRevision(tx, &existingPost, updateData.Title, updateData.Body, userID, now)
+	})
+	if err == errUploadConsumed {
+		removeAttachmentFiles(addedAttachments)
+		c.JSON(http.StatusConflict, gin.H{"error": "Invalid media", "detail": err.Error()})
+		return
+	}
+	if exceeded, ok := err.(*quotaExceededError); ok {
+		removeAttachmentFiles(addedAttachments)
+		c.JSON(http.StatusRequestEntityTooLarge, quotaExceededResponse(exceeded))
+		return
+	}
+	if err != nil {
+		removeAttachmentFiles(addedAttachments)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
 		return
 	}
+	removeAttachmentFiles(removedAttachments)
+	removeConsumedUploads(uploads)
+	queueAttachmentVariants(addedAttachments)
+	if contentChanged {
+		syncMentions(db, existingPost.UserID, existingPost.ForumID, postID, nil, updateData.Title+"\n"+updateData.Body)
+	}
 
 	ch.Delete(fmt.Sprintf("posts_forum_%s", existingPost.ForumID.String()))
 	ch.Delete(fmt.Sprintf("post_%d", postID))
 
-	c.JSON(http.StatusOK, gin.H{"message": "Post updated successfully"})
+	response := gin.H{"message": "Post updated successfully"}
+	if mediaChanged {
+		updated := append(keptAttachments, addedAttachments...)
+		resolveAttachmentURLs(updated, true)
+		response["attachments"] = updated
+	}
+	if tagsChanged {
+		response["tags"] = tagNames
+	}
+	if flairChanged {
+		response["flair_id"] = newFlairID
+		response["flair"] = flair
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func DeletePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
@@ -525,18 +1016,52 @@ func DeletePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
-	_, err = db.Model(&post).Where("id = ?", postID).Delete()
-	if err != nil {
+	if err := removePost(db, ch, &post, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Delete failed"})
 		return
 	}
 
-	ch.Delete(fmt.Sprintf("posts_forum_%s", post.ForumID.String()))
-	ch.Delete(fmt.Sprintf("post_%d", postID))
+	if post.UserID != userID {
+		recordAuditLog(db, &Models.AuditLogs{
+			ActorID:    userID,
+			ForumID:    &post.ForumID,
+			Action:     "post.delete",
+			TargetType: "post",
+			TargetID:   strconv.Itoa(post.ID),
+			Before:     post,
+			Reason:     c.Query("reason"),
+		})
+		notifyModerationAction(db, userID, post.UserID, post.ForumID, &post.ID, nil, "post.delete", c.Query("reason"))
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Post deleted successfully"})
 }
 
+func removePost(db *pg.DB, ch *cache.Cache, post *Models.Posts, deletedBy uuid.UUID) error {
+	post.DeletedAt = time.Now()
+	post.DeletedBy = &deletedBy
+	err := db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if err := clearPinPosition(tx, post); err != nil {
+			return err
+		}
+		_, err := tx.Model(post).
+			Set("deleted_at = ?", post.DeletedAt).
+			Set("deleted_by = ?", deletedBy).
+			Where("id = ?", post.ID).
+			Update()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	post.PinPosition = nil
+
+	ch.Delete(fmt.Sprintf("posts_forum_%s", post.ForumID.String()))
+	ch.Delete(fmt.Sprintf("post_%d", post.ID))
+	ch.Delete(fmt.Sprintf("comments_post_%d", post.ID))
+	return nil
+}
+
 func CreateComment(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -551,7 +1076,35 @@ func CreateComment(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 	}
 
 	comment.UserID = userID
+	comment.BodyHTML = renderMarkdown(comment.Body)
 	comment.CreatedAt = time.Now()
+	comment.EditedAt = nil
+	comment.DeletedAt = time.Time{}
+	comment.DeletedBy = nil
+
+	var post Models.Posts
+	err = db.Model(&post).Column("forum_id", "is_locked").Where("id = ?", comment.PostID).Select()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Post not found"})
+		return
+	}
+
+	if rejectLockedPost(c, &post, "New comments are not allowed on this post") {
+		return
+	}
+
+	ban, err := getActiveForumBan(db, post.ForumID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum access"})
+		return
+	}
+	if ban != nil {
+		c.JSON(http.StatusForbidden, forumBanResponse(ban))
+		return
+	}
+	if rejectPrivateForum(c, db, userID, post.ForumID) {
+		return
+	}
 
 	if comment.ParentCommentID != 0 {
 		var parent Models.Comments
@@ -569,6 +1122,10 @@ func CreateComment(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 	}
 
 	ch.Delete(fmt.Sprintf("comments_post_%d", comment.PostID))
+	publishCommentCreated(post.ForumID, &comment)
+	enqueueWebhookEvent(db, post.ForumID, webhookCommentCreated, comment)
+	notifyReply(db, &comment, post.ForumID)
+	syncMentions(db, userID, post.ForumID, comment.PostID, &comment.ID, comment.Body)
 	c.JSON(http.StatusCreated, gin.H{"message": "Comment created", "comment": comment})
 }
 
@@ -580,6 +1137,22 @@ func GetPostComments(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
+	var post Models.Posts
+	err = db.Model(&post).Column("forum_id").Where("id = ?", postID).Select()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	userIDInterface, _ := c.Get("user_id")
+	var currentUser uuid.UUID
+	if uid, ok := userIDInterface.(uuid.UUID); ok {
+		currentUser = uid
+	}
+	if rejectPrivateForum(c, db, currentUser, post.ForumID) {
+		return
+	}
+
 	cacheKey := fmt.Sprintf("comments_post_%d", postID)
 	if saved, found := ch.Get(cacheKey); found {
 		c.JSON(http.StatusOK, gin.H{"comments": saved})
@@ -589,6 +1162,7 @@ func GetPostComments(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 	var comments []Models.Comments
 	err = db.Model(&comments).
 		Relation("User").
+		AllWithDeleted().
 		Where("post_id = ?", postID).
 		Order("created_at ASC").
 		Select()
@@ -625,6 +1199,7 @@ func GetPostComments(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 
 	type CommentWithCounts struct {
 		Models.Comments
+		IsDeleted bool `json:"is_deleted"`
 		Upvotes   int  `json:"upvotes"`
 		Downvotes int  `json:"downvotes"`
 		MyVote    *int `json:"my_vote"`
@@ -632,8 +1207,25 @@ func GetPostComments(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 	result := make([]CommentWithCounts, 0, len(comments))
 	for _, cmt := range comments {
 		count := cMap[cmt.ID]
+		isDeleted := !cmt.DeletedAt.IsZero()
+		if isDeleted {
+			body := "[deleted]"
+			if cmt.DeletedBy != nil && *cmt.DeletedBy != cmt.UserID {
+				body = "[removed]"
+			}
+			cmt = Models.Comments{
+				ID:              cmt.ID,
+				PostID:          cmt.PostID,
+				ParentCommentID: cmt.ParentCommentID,
+				Body:            body,
+				BodyHTML:        renderMarkdown(body),
+				CreatedAt:       cmt.CreatedAt,
+				DeletedAt:       cmt.DeletedAt,
+			}
+		}
 		result = append(result, CommentWithCounts{
 			Comments:  cmt,
+			IsDeleted: isDeleted,
 			Upvotes:   count.Up,
 			Downvotes: count.Down,
 			MyVote:    nil,
@@ -681,16 +1273,242 @@ func DeleteComment(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
-	_, err = db.Model(&comment).Where("id = ?", commentID).Delete()
-	if err != nil {
+	if err := removeComment(db, ch, &comment, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Delete failed"})
 		return
 	}
 
-	ch.Delete(fmt.Sprintf("comments_post_%d", comment.PostID))
+	if comment.UserID != userID {
+		forumID, _ := getForumIDByPostID(db, comment.PostID)
+		recordAuditLog(db, &Models.AuditLogs{
+			ActorID:    userID,
+			ForumID:    &forumID,
+			Action:     "comment.delete",
+			TargetType: "comment",
+			TargetID:   strconv.Itoa(comment.ID),
+			Before:     comment,
+			Reason:     c.Query("reason"),
+		})
+		notifyModerationAction(db, userID, comment.UserID, forumID, &comment.PostID, &comment.ID, "comment.delete", c.Query("reason"))
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
 }
 
+func removeComment(db *pg.DB, ch *cache.Cache, comment *Models.Comments, deletedBy uuid.UUID) error {
+	comment.DeletedAt = time.Now()
+	comment.DeletedBy = &deletedBy
+	_, err := db.Model(comment).
+		Set("deleted_at = ?", comment.DeletedAt).
+		Set("deleted_by = ?", deletedBy).
+		Where("id = ?", comment.ID).
+		Update()
+	if err != nil {
+		return err
+	}
+
+	ch.Delete(fmt.Sprintf("comments_post_%d", comment.PostID))
+	return nil
+}
+
+const softDeleteRetention = 30 * 24 * time.Hour
+
+func canRestore(db *pg.DB, userID uuid.UUID, authorID uuid.UUID, deletedBy *uuid.UUID, forumID uuid.UUID) (bool, bool) {
+	if isSysAdmin, _ := isSystemAdmin(db, userID); isSysAdmin {
+		return true, true
+	}
+	selfDeleted := deletedBy != nil && *deletedBy == authorID
+	// Forum admins may only undo removals made at forum level, not those
+	// made by system admins or by the author.
+	if deletedBy != nil && !selfDeleted && isForumAdmin(db, userID, forumID) {
+		deleterIsSysAdmin, _ := isSystemAdmin(db, *deletedBy)
+		if !deleterIsSysAdmin && isForumAdmin(db, *deletedBy, forumID) {
+			return true, true
+		}
+	}
+	return authorID == userID && selfDeleted, false
+}
+
+func RestorePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	postID, err := strconv.Atoi(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var post Models.Posts
+	err = db.Model(&post).Deleted().Where("id = ?", postID).Select()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted post not found"})
+		return
+	}
+
+	allowed, isModerator := canRestore(db, userID, post.UserID, post.DeletedBy, post.ForumID)
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if time.Since(post.DeletedAt) > softDeleteRetention {
+		c.JSON(http.StatusGone, gin.H{"error": "Restore window has expired"})
+		return
+	}
+
+	_, err = db.Model(&post).
+		Deleted().
+		Set("deleted_at = NULL").
+		Set("deleted_by = NULL").
+		Where("id = ?", postID).
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Restore failed"})
+		return
+	}
+
+	if isModerator && post.UserID != userID {
+		recordAuditLog(db, &Models.AuditLogs{
+			ActorID:    userID,
+			ForumID:    &post.ForumID,
+			Action:     "post.restore",
+			TargetType: "post",
+			TargetID:   strconv.Itoa(post.ID),
+			Before:     gin.H{"deleted_at": post.DeletedAt, "deleted_by": post.DeletedBy},
+		})
+		notifyModerationAction(db, userID, post.UserID, post.ForumID, &post.ID, nil, "post.restore", "")
+	}
+
+	ch.Delete(fmt.Sprintf("posts_forum_%s", post.ForumID.String()))
+	ch.Delete(fmt.Sprintf("post_%d", postID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Post restored successfully"})
+}
+
+func RestoreComment(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	commentID, err := strconv.Atoi(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var comment Models.Comments
+	err = db.Model(&comment).Deleted().Where("id = ?", commentID).Select()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted comment not found"})
+		return
+	}
+
+	forumID, err := getForumIDByPostID(db, comment.PostID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	allowed, isModerator := canRestore(db, userID, comment.UserID, comment.DeletedBy, forumID)
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if time.Since(comment.DeletedAt) > softDeleteRetention {
+		c.JSON(http.StatusGone, gin.H{"error": "Restore window has expired"})
+		return
+	}
+
+	_, err = db.Model(&comment).
+		Deleted().
+		Set("deleted_at = NULL").
+		Set("deleted_by = NULL").
+		Where("id = ?", commentID).
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Restore failed"})
+		return
+	}
+
+	if isModerator && comment.UserID != userID {
+		recordAuditLog(db, &Models.AuditLogs{
+			ActorID:    userID,
+			ForumID:    &forumID,
+			Action:     "comment.restore",
+			TargetType: "comment",
+			TargetID:   strconv.Itoa(comment.ID),
+			Before:     gin.H{"deleted_at": comment.DeletedAt, "deleted_by": comment.DeletedBy},
+		})
+		notifyModerationAction(db, userID, comment.UserID, forumID, &comment.PostID, &comment.ID, "comment.restore", "")
+	}
+
+	ch.Delete(fmt.Sprintf("comments_post_%d", comment.PostID))
+	c.JSON(http.StatusOK, gin.H{"message": "Comment restored"})
+}
+
+func StartSoftDeletePurgeJob(db *pg.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-softDeleteRetention)
+
+		var postIDs []int
+		err := db.Model((*Models.Posts)(nil)).
+			Deleted().
+			Column("id").
+			Where("deleted_at <= ?", cutoff).
+			Select(&postIDs)
+		if err != nil {
+			log.Printf("Soft Delete Purge Job Failed (posts): %v", err)
+		} else if len(postIDs) > 0 {
+			keys, err := mediaKeysForPosts(db, postIDs)
+			if err != nil {
+				log.Printf("Soft Delete Purge Job Failed (media): %v", err)
+			}
+			res, err := db.Model((*Models.Posts)(nil)).
+				Deleted().
+				Where("id IN (?)", pg.In(postIDs)).
+				ForceDelete()
+			if err != nil {
+				log.Printf("Soft Delete Purge Job Failed (posts): %v", err)
+			} else {
+				deleteStoredMedia(keys)
+				log.Printf("Soft Delete Purge Job: purged %d posts", res.RowsAffected())
+			}
+		}
+
+		// Replies cascade with their parent, so only purge deleted comments
+		// that no longer have replies and repeat until the thread is clean.
+		purged := 0
+		for {
+			res, err := db.Model((*Models.Comments)(nil)).
+				Deleted().
+				Where("deleted_at <= ?", cutoff).
+				Where("NOT EXISTS (SELECT 1 FROM comments AS child WHERE child.parent_comment_id = comments.id)").
+				ForceDelete()
+			if err != nil {
+				log.Printf("Soft Delete Purge Job Failed (comments): %v", err)
+				break
+			}
+			if res.RowsAffected() == 0 {
+				break
+			}
+			purged += res.RowsAffected()
+		}
+		if purged > 0 {
+			log.Printf("Soft Delete Purge Job: purged %d comments", purged)
+		}
+	}
+}
+
 func UpdateComment(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 	commentIDStr := c.Param("comment_id")
 	commentID, err := strconv.Atoi(commentIDStr)
@@ -728,16 +1546,44 @@ func UpdateComment(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		return
 	}
 
-	_, err = db.Model(&existing).
-		Set("body = ?", payload.Body).
-		Set("created_at = ?", existing.CreatedAt).
-		Where("id = ?", commentID).
-		Update()
+	now := time.Now()
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		if payload.Body == existing.Body {
+			return nil
+		}
+		_, err := tx.Model(&existing).
+			Set("body = ?", payload.Body).
+			Set("body_html = ?", renderMarkdown(payload.Body)).
+			Set("edited_at = ?", now).
+			Where("id = ?", commentID).
+			Update()
+		if err != nil {
+			return err
+		}
+		return saveCommentRevision(tx, &existing, payload.Body, userID, now)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
 		return
 	}
 
+	forumID, _ := getForumIDByPostID(db, existing.PostID)
+	if payload.Body != existing.Body {
+		syncMentions(db, existing.UserID, forumID, existing.PostID, &existing.ID, payload.Body)
+	}
+	if existing.UserID != userID {
+		recordAuditLog(db, &Models.AuditLogs{
+			ActorID:    userID,
+			ForumID:    &forumID,
+			Action:     "comment.update",
+			TargetType: "comment",
+			TargetID:   strconv.Itoa(existing.ID),
+			Before:     gin.H{"body": existing.Body},
+			After:      gin.H{"body": payload.Body},
+		})
+		notifyModerationAction(db, userID, existing.UserID, forumID, &existing.PostID, &existing.ID, "comment.update", "")
+	}
+
 	ch.Delete("comments_post_" + strconv.Itoa(existing.PostID))
 	c.JSON(http.StatusOK, gin.H{"message": "Comment updated"})
 }
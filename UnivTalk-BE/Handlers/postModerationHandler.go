@@ -0,0 +1,433 @@
+package Handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const maxPinnedPosts = 3
+
+var (
+	errPinLimitReached = fmt.Errorf("pin limit reached")
+	errInvalidPinOrder = fmt.Errorf("post_ids must list every pinned post of this forum exactly once")
+)
+
+func sortPinnedFirst(posts []Models.PostWithCounts) {
+	sort.SliceStable(posts, func(i, j int) bool {
+		pi, pj := posts[i].PinPosition, posts[j].PinPosition
+		if pi == nil || pj == nil {
+			return pi != nil && pj == nil
+		}
+		return *pi < *pj
+	})
+}
+
+func sortAnnouncementsFirst(posts []Models.PostWithCounts) {
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].IsAnnouncement && !posts[j].IsAnnouncement
+	})
+}
+
+func invalidatePostCache(ch *cache.Cache, post *Models.Posts) {
+	ch.Delete("global_posts")
+	ch.Delete(fmt.Sprintf("posts_forum_%s", post.ForumID.String()))
+	ch.Delete(fmt.Sprintf("post_%d", post.ID))
+}
+
+func loadModeratedPost(c *gin.Context, db *pg.DB) (*Models.Posts, uuid.UUID, bool) {
+	postID, err := strconv.Atoi(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID format"})
+		return nil, uuid.Nil, false
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, uuid.Nil, false
+	}
+
+	var post Models.Posts
+	if err := db.Model(&post).Where("id = ?", postID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, uuid.Nil, false
+	}
+
+	hasAccess, err := canModerateForum(db, userID, post.ForumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return nil, uuid.Nil, false
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to moderate this post",
+		})
+		return nil, uuid.Nil, false
+	}
+
+	return &post, userID, true
+}
+
+func setPostLocked(db *pg.DB, ch *cache.Cache, post *Models.Posts, locked bool) error {
+	_, err := db.Model(post).
+		Set("is_locked = ?", locked).
+		Where("id = ?", post.ID).
+		Update()
+	if err != nil {
+		return err
+	}
+	post.IsLocked = locked
+	invalidatePostCache(ch, post)
+	return nil
+}
+
+func LockPost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	togglePostLock(c, db, ch, true)
+}
+
+func UnlockPost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	togglePostLock(c, db, ch, false)
+}
+
+func togglePostLock(c *gin.Context, db *pg.DB, ch *cache.Cache, locked bool) {
+	post, userID, ok := loadModeratedPost(c, db)
+	if !ok {
+		return
+	}
+
+	if post.IsLocked == locked {
+		c.JSON(http.StatusOK, gin.H{"message": "No change", "is_locked": locked})
+		return
+	}
+
+	if err := setPostLocked(db, ch, post, locked); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post lock"})
+		return
+	}
+
+	action := "post.lock"
+	if !locked {
+		action = "post.unlock"
+	}
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &post.ForumID,
+		Action:     action,
+		TargetType: "post",
+		TargetID:   strconv.Itoa(post.ID),
+		Before:     gin.H{"is_locked": !locked},
+		After:      gin.H{"is_locked": locked},
+		Reason:     c.Query("reason"),
+	})
+	notifyModerationAction(db, userID, post.UserID, post.ForumID, &post.ID, nil, action, c.Query("reason"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Post lock updated", "is_locked": locked})
+}
+
+func clearPinPosition(tx *pg.Tx, post *Models.Posts) error {
+	var forum Models.Forums
+	err := tx.Model(&forum).Where("fid = ?", post.ForumID).For("UPDATE").Select()
+	if err != nil {
+		return err
+	}
+	var current Models.Posts
+	err = tx.Model(&current).Column("pin_position").Where("id = ?", post.ID).Select()
+	if err != nil || current.PinPosition == nil {
+		return err
+	}
+
+	_, err = tx.Model((*Models.Posts)(nil)).
+		Set("pin_position = NULL").
+		Where("id = ?", post.ID).
+		Update()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Model((*Models.Posts)(nil)).
+		Set("pin_position = pin_position - 1").
+		Where("forum_id = ?", post.ForumID).
+		Where("pin_position > ?", *current.PinPosition).
+		Update()
+	return err
+}
+
+func PinPost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	post, userID, ok := loadModeratedPost(c, db)
+	if !ok {
+		return
+	}
+
+	if post.PinPosition != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "No change", "pin_position": *post.PinPosition})
+		return
+	}
+
+	var position int
+	err := db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		var forum Models.Forums
+		err := tx.Model(&forum).Where("fid = ?", post.ForumID).For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+		var pinned int
+		err = tx.Model((*Models.Posts)(nil)).
+			ColumnExpr("COUNT(*)").
+			ColumnExpr("COALESCE(MAX(pin_position), 0) + 1").
+			Where("forum_id = ?", post.ForumID).
+			Where("pin_position IS NOT NULL").
+			Select(&pinned, &position)
+		if err != nil {
+			return err
+		}
+		if pinned >= maxPinnedPosts {
+			return errPinLimitReached
+		}
+		_, err = tx.Model(post).
+			Set("pin_position = ?", position).
+			Where("id = ?", post.ID).
+			Update()
+		return err
+	})
+	if err == errPinLimitReached {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Pin limit reached",
+			"detail": fmt.Sprintf("A forum can have at most %d pinned posts", maxPinnedPosts),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pin post"})
+		return
+	}
+
+	post.PinPosition = &position
+	invalidatePostCache(ch, post)
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &post.ForumID,
+		Action:     "post.pin",
+		TargetType: "post",
+		TargetID:   strconv.Itoa(post.ID),
+		After:      gin.H{"pin_position": position},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Post pinned", "pin_position": position})
+}
+
+func UnpinPost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	post, userID, ok := loadModeratedPost(c, db)
+	if !ok {
+		return
+	}
+
+	if post.PinPosition == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "No change"})
+		return
+	}
+
+	err := db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		return clearPinPosition(tx, post)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpin post"})
+		return
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &post.ForumID,
+		Action:     "post.unpin",
+		TargetType: "post",
+		TargetID:   strconv.Itoa(post.ID),
+		Before:     gin.H{"pin_position": *post.PinPosition},
+	})
+	invalidatePostCache(ch, post)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Post unpinned"})
+}
+
+func ReorderPinnedPosts(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumIDStr := c.Param("forum_id")
+	forumID, err := uuid.Parse(forumIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to reorder pinned posts in this forum",
+		})
+		return
+	}
+
+	var payload struct {
+		PostIDs []int `json:"post_ids"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		var forum Models.Forums
+		err := tx.Model(&forum).Where("fid = ?", forumID).For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+		var pinnedIDs []int
+		err = tx.Model((*Models.Posts)(nil)).
+			Column("id").
+			Where("forum_id = ?", forumID).
+			Where("pin_position IS NOT NULL").
+			For("UPDATE").
+			Select(&pinnedIDs)
+		if err != nil {
+			return err
+		}
+
+		pinnedSet := make(map[int]bool, len(pinnedIDs))
+		for _, id := range pinnedIDs {
+			pinnedSet[id] = true
+		}
+		seen := make(map[int]bool, len(payload.PostIDs))
+		for _, id := range payload.PostIDs {
+			if !pinnedSet[id] || seen[id] {
+				return errInvalidPinOrder
+			}
+			seen[id] = true
+		}
+		if len(seen) != len(pinnedSet) {
+			return errInvalidPinOrder
+		}
+
+		for i, id := range payload.PostIDs {
+			_, err := tx.Model((*Models.Posts)(nil)).
+				Set("pin_position = ?", i+1).
+				Where("id = ?", id).
+				Update()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == errInvalidPinOrder {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pin order", "detail": err.Error()})
+		return
+	}
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forum not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder pinned posts"})
+		return
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "forum.reorder_pins",
+		TargetType: "forum",
+		TargetID:   forumID.String(),
+		After:      gin.H{"post_ids": payload.PostIDs},
+	})
+	ch.Delete("global_posts")
+	ch.Delete(fmt.Sprintf("posts_forum_%s", forumIDStr))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pinned posts reordered"})
+}
+
+func AnnouncePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	toggleAnnouncement(c, db, ch, true)
+}
+
+func UnannouncePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	toggleAnnouncement(c, db, ch, false)
+}
+
+func toggleAnnouncement(c *gin.Context, db *pg.DB, ch *cache.Cache, announce bool) {
+	postID, err := strconv.Atoi(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	isSysAdmin, err := isSystemAdmin(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !isSysAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "Only system admins can manage announcements",
+		})
+		return
+	}
+
+	var post Models.Posts
+	if err := db.Model(&post).Where("id = ?", postID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	if post.IsAnnouncement == announce {
+		c.JSON(http.StatusOK, gin.H{"message": "No change", "is_announcement": announce})
+		return
+	}
+
+	_, err = db.Model(&post).
+		Set("is_announcement = ?", announce).
+		Where("id = ?", postID).
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update announcement"})
+		return
+	}
+
+	action := "post.announce"
+	if !announce {
+		action = "post.unannounce"
+	}
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &post.ForumID,
+		Action:     action,
+		TargetType: "post",
+		TargetID:   strconv.Itoa(post.ID),
+		Before:     gin.H{"is_announcement": !announce},
+		After:      gin.H{"is_announcement": announce},
+	})
+	invalidatePostCache(ch, &post)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Announcement updated", "is_announcement": announce})
+}
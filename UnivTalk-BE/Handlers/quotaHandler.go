@@ -0,0 +1,284 @@
+package Handlers
+
+import (
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	defaultUserQuotaMB  = 1024
+	defaultForumQuotaMB = 10240
+)
+
+type storageUsage struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
+	IsCustom   bool  `json:"is_custom"`
+}
+
+type quotaExceededError struct {
+	Scope string
+	Usage storageUsage
+}
+
+func (e *quotaExceededError) Error() string {
+	return fmt.Sprintf("%s storage quota exceeded: %d of %d bytes used", e.Scope, e.Usage.UsedBytes, e.Usage.QuotaBytes)
+}
+
+func defaultQuota(env string, fallbackMB int64) int64 {
+	if mb, err := strconv.ParseInt(os.Getenv(env), 10, 64); err == nil && mb >= 0 {
+		return mb << 20
+	}
+	return fallbackMB << 20
+}
+
+// userStorageUsage counts the attachments on the user's posts and messages
+// plus their unexpired resumable uploads, which reserve their full declared
+// size from the moment they are created.
+func userStorageUsage(db orm.DB, userID uuid.UUID) (storageUsage, error) {
+	var user Models.Users
+	if err := db.Model(&user).Column("storage_quota_bytes").Where("uid = ?", userID).Select(); err != nil {
+		return storageUsage{}, err
+	}
+	usage := storageUsage{QuotaBytes: defaultQuota("USER_STORAGE_QUOTA_MB", defaultUserQuotaMB)}
+	if user.StorageQuota != nil {
+		usage.QuotaBytes, usage.IsCustom = *user.StorageQuota, true
+	}
+
+	_, err := db.QueryOne(pg.Scan(&usage.UsedBytes), `
+		SELECT COALESCE((SELECT SUM(size_bytes) FROM post_attachments WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?0)), 0)
+		     + COALESCE((SELECT SUM(a.size_bytes) FROM message_attachments a JOIN messages m ON m.id = a.message_id WHERE m.sender_id = ?0), 0)
+		     + COALESCE((SELECT SUM(size_bytes) FROM media_uploads WHERE user_id = ?0 AND status <> 'failed' AND expires_at > ?1), 0)
+	`, userID, time.Now())
+	return usage, err
+}
+
+func forumStorageUsage(db orm.DB, forumID uuid.UUID) (storageUsage, error) {
+	var forum Models.Forums
+	if err := db.Model(&forum).Column("storage_quota_bytes").Where("fid = ?", forumID).Select(); err != nil {
+		return storageUsage{}, err
+	}
+	usage := storageUsage{QuotaBytes: defaultQuota("FORUM_STORAGE_QUOTA_MB", defaultForumQuotaMB)}
+	if forum.StorageQuota != nil {
+		usage.QuotaBytes, usage.IsCustom = *forum.StorageQuota, true
+	}
+
+	err := db.Model((*Models.PostAttachments)(nil)).
+		ColumnExpr("COALESCE(SUM(size_bytes), 0)").
+		Where("post_id IN (SELECT id FROM posts WHERE forum_id = ?)", forumID).
+		Select(&usage.UsedBytes)
+	return usage, err
+}
+
+// incomingMediaSize returns the size of newly uploaded files and of completed
+// resumable uploads. The latter are already part of the user's usage, so only
+// the forum quota has to add them.
+func incomingMediaSize(files []*multipart.FileHeader, uploads []Models.MediaUploads) (int64, int64) {
+	var incoming, reserved int64
+	for _, f := range files {
+		incoming += f.Size
+	}
+	for _, u := range uploads {
+		reserved += u.SizeBytes
+	}
+	return incoming, reserved
+}
+
+func checkStorageQuota(db orm.DB, userID uuid.UUID, forumID uuid.UUID, incoming, reserved int64) error {
+	if incoming+reserved == 0 {
+		return nil
+	}
+	usage, err := userStorageUsage(db, userID)
+	if err != nil {
+		return err
+	}
+	if usage.UsedBytes+incoming > usage.QuotaBytes {
+		return &quotaExceededError{Scope: "user", Usage: usage}
+	}
+	if forumID == uuid.Nil {
+		return nil
+	}
+	usage, err = forumStorageUsage(db, forumID)
+	if err != nil {
+		return err
+	}
+	if usage.UsedBytes+incoming+reserved > usage.QuotaBytes {
+		return &quotaExceededError{Scope: "forum", Usage: usage}
+	}
+	return nil
+}
+
+// checkStorageQuotaLocked repeats checkStorageQuota inside the transaction
+// that stores the media, holding advisory locks on the user and forum so
+// concurrent requests cannot both pass against the same usage.
+func checkStorageQuotaLocked(tx *pg.Tx, userID uuid.UUID, forumID uuid.UUID, incoming, reserved int64) error {
+	if incoming+reserved == 0 {
+		return nil
+	}
+	keys := []string{"storage_quota:user:" + userID.String()}
+	if forumID != uuid.Nil {
+		keys = append(keys, "storage_quota:forum:"+forumID.String())
+	}
+	for _, key := range keys {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", key); err != nil {
+			return err
+		}
+	}
+	return checkStorageQuota(tx, userID, forumID, incoming, reserved)
+}
+
+func quotaExceededResponse(err *quotaExceededError) gin.H {
+	return gin.H{
+		"error":   "Storage quota exceeded",
+		"detail":  err.Error(),
+		"scope":   err.Scope,
+		"storage": err.Usage,
+	}
+}
+
+func bindStorageQuota(c *gin.Context) (*int64, bool) {
+	var payload struct {
+		QuotaBytes *int64 `json:"quota_bytes"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return nil, false
+	}
+	if payload.QuotaBytes != nil && *payload.QuotaBytes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quota_bytes must not be negative"})
+		return nil, false
+	}
+	return payload.QuotaBytes, true
+}
+
+func requireSystemAdmin(c *gin.Context, db *pg.DB, detail string) (uuid.UUID, bool) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return uuid.Nil, false
+	}
+	isSysAdmin, err := isSystemAdmin(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return uuid.Nil, false
+	}
+	if !isSysAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "detail": detail})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+func SetUserStorageQuota(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	actorID, ok := requireSystemAdmin(c, db, "Only system admins can change storage quotas")
+	if !ok {
+		return
+	}
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+	quota, ok := bindStorageQuota(c)
+	if !ok {
+		return
+	}
+
+	before, err := userStorageUsage(db, targetID)
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve storage usage"})
+		return
+	}
+
+	_, err = db.Model((*Models.Users)(nil)).
+		Set("storage_quota_bytes = ?", quota).
+		Where("uid = ?", targetID).
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update storage quota"})
+		return
+	}
+	after, err := userStorageUsage(db, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve storage usage"})
+		return
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    actorID,
+		Action:     "user.storage_quota",
+		TargetType: "user",
+		TargetID:   targetID.String(),
+		Before:     before,
+		After:      after,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Storage quota updated", "storage": after})
+}
+
+func SetForumStorageQuota(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	actorID, ok := requireSystemAdmin(c, db, "Only system admins can change storage quotas")
+	if !ok {
+		return
+	}
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+	quota, ok := bindStorageQuota(c)
+	if !ok {
+		return
+	}
+
+	before, err := forumStorageUsage(db, forumID)
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forum not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve storage usage"})
+		return
+	}
+
+	_, err = db.Model((*Models.Forums)(nil)).
+		Set("storage_quota_bytes = ?", quota).
+		Where("fid = ?", forumID).
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update storage quota"})
+		return
+	}
+	after, err := forumStorageUsage(db, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve storage usage"})
+		return
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    actorID,
+		ForumID:    &forumID,
+		Action:     "forum.storage_quota",
+		TargetType: "forum",
+		TargetID:   forumID.String(),
+		Before:     before,
+		After:      after,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Storage quota updated", "storage": after})
+}
@@ -0,0 +1,546 @@
+package Handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	realtimeBufferSize       = 64
+	realtimeMaxSubscriptions = 50
+	realtimeHeartbeat        = 25 * time.Second
+	realtimeWriteTimeout     = 10 * time.Second
+	realtimeChannel          = "univtalk_realtime"
+	// Postgres rejects NOTIFY payloads of 8000 bytes or more.
+	realtimeMaxPayload = 7900
+)
+
+type realtimeEvent struct {
+	Type      string      `json:"type"`
+	Topic     string      `json:"topic"`
+	Data      interface{} `json:"data"`
+	Truncated bool        `json:"truncated,omitempty"`
+	At        time.Time   `json:"at"`
+}
+
+// realtimeMessage is what instances exchange over NOTIFY: an event to fan out
+// to local subscribers, or a request to re-check a forum's subscriptions after
+// someone lost access to it.
+type realtimeMessage struct {
+	Event  *realtimeEvent  `json:"event,omitempty"`
+	Revoke *realtimeRevoke `json:"revoke,omitempty"`
+}
+
+type realtimeRevoke struct {
+	ForumID uuid.UUID `json:"forum_id"`
+	// uuid.Nil re-checks every subscriber of the forum.
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type realtimeSubscriber struct {
+	userID  uuid.UUID
+	events  chan realtimeEvent
+	dropped chan struct{}
+	once    sync.Once
+}
+
+func (s *realtimeSubscriber) drop() {
+	s.once.Do(func() { close(s.dropped) })
+}
+
+type realtimeHub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*realtimeSubscriber]bool
+	// Set once StartRealtimeListener runs; until then events stay local.
+	db *pg.DB
+}
+
+var (
+	hub            = &realtimeHub{topics: make(map[string]map[*realtimeSubscriber]bool)}
+	allowedOrigins []string
+)
+
+func SetAllowedOrigins(origins []string) {
+	allowedOrigins = origins
+}
+
+func forumTopic(forumID uuid.UUID) string { return "forum:" + forumID.String() }
+func postTopic(postID int) string         { return "post:" + strconv.Itoa(postID) }
+func userTopic(userID uuid.UUID) string   { return "user:" + userID.String() }
+
+func (h *realtimeHub) subscribe(s *realtimeSubscriber, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[*realtimeSubscriber]bool)
+	}
+	h.topics[topic][s] = true
+}
+
+func (h *realtimeHub) unsubscribe(s *realtimeSubscriber, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.topics[topic], s)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+func (h *realtimeHub) publish(topic string, eventType string, data interface{}) {
+	event := realtimeEvent{Type: eventType, Topic: topic, Data: data, At: time.Now()}
+	if !h.notify(realtimeMessage{Event: &event}) {
+		h.deliver(event)
+	}
+}
+
+// notify sends msg to every instance, including this one, through NOTIFY. It
+// returns false when the listener is not running or the NOTIFY failed, so the
+// caller can handle the message locally instead.
+func (h *realtimeHub) notify(msg realtimeMessage) bool {
+	h.mu.RLock()
+	db := h.db
+	h.mu.RUnlock()
+	if db == nil {
+		return false
+	}
+
+	payload, err := json.Marshal(msg)
+	if err == nil && len(payload) > realtimeMaxPayload && msg.Event != nil {
+		// Too large for NOTIFY: clients get the event without its data and
+		// refetch it over the REST API.
+		trimmed := *msg.Event
+		trimmed.Data, trimmed.Truncated = nil, true
+		payload, err = json.Marshal(realtimeMessage{Event: &trimmed})
+	}
+	if err != nil {
+		log.Printf("Encode Realtime Message Failed: %v", err)
+		return false
+	}
+	if _, err := db.Exec("SELECT pg_notify(?, ?)", realtimeChannel, string(payload)); err != nil {
+		log.Printf("Notify Realtime Message Failed: %v", err)
+		return false
+	}
+	return true
+}
+
+func (h *realtimeHub) deliver(event realtimeEvent) {
+	topic := event.Topic
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.topics[topic] {
+		select {
+		case s.events <- event:
+		default:
+			// A client that cannot keep up is disconnected so it can
+			// reconnect and refetch instead of silently missing events.
+			s.drop()
+		}
+	}
+}
+
+func topicForum(db *pg.DB, topic string) (uuid.UUID, bool) {
+	kind, id, _ := strings.Cut(topic, ":")
+	switch kind {
+	case "forum", "chat":
+		forumID, err := uuid.Parse(id)
+		return forumID, err == nil
+	case "post":
+		postID, err := strconv.Atoi(id)
+		if err != nil {
+			return uuid.Nil, false
+		}
+		forumID, err := getForumIDByPostID(db, postID)
+		return forumID, err == nil
+	}
+	return uuid.Nil, false
+}
+
+func canAccessTopic(db *pg.DB, userID uuid.UUID, topic string, forumID uuid.UUID) (bool, error) {
+	if strings.HasPrefix(topic, "chat:") {
+		return canReadForumChat(db, userID, forumID)
+	}
+	return canViewForum(db, userID, forumID)
+}
+
+// revoke drops the forum, post and chat subscriptions of users who can no
+// longer see them, and tells their clients with a subscription.revoked event.
+func (h *realtimeHub) revoke(db *pg.DB, r realtimeRevoke) {
+	type subscription struct {
+		s     *realtimeSubscriber
+		topic string
+	}
+	candidates := make([]subscription, 0)
+	h.mu.RLock()
+	for topic, subscribers := range h.topics {
+		for s := range subscribers {
+			if r.UserID == uuid.Nil || s.userID == r.UserID {
+				candidates = append(candidates, subscription{s, topic})
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	access := make(map[string]bool)
+	for _, sub := range candidates {
+		if forumID, ok := topicForum(db, sub.topic); !ok || forumID != r.ForumID {
+			continue
+		}
+		key := sub.s.userID.String()
+		if strings.HasPrefix(sub.topic, "chat:") {
+			key += ":chat"
+		}
+		allowed, checked := access[key]
+		if !checked {
+			var err error
+			allowed, err = canAccessTopic(db, sub.s.userID, sub.topic, r.ForumID)
+			if err != nil && err != pg.ErrNoRows {
+				log.Printf("Check Realtime Access Failed: %v", err)
+				continue
+			}
+			access[key] = allowed
+		}
+		if allowed {
+			continue
+		}
+		h.unsubscribe(sub.s, sub.topic)
+		select {
+		case sub.s.events <- realtimeEvent{Type: "subscription.revoked", Topic: sub.topic, At: time.Now()}:
+		default:
+		}
+	}
+}
+
+// revokeForumSubscriptions re-checks the realtime subscriptions userID holds
+// for the forum on every instance; pass uuid.Nil to re-check all subscribers.
+func revokeForumSubscriptions(db *pg.DB, forumID uuid.UUID, userID uuid.UUID) {
+	r := realtimeRevoke{ForumID: forumID, UserID: userID}
+	if !hub.notify(realtimeMessage{Revoke: &r}) {
+		hub.revoke(db, r)
+	}
+}
+
+// StartRealtimeListener routes published events through Postgres
+// LISTEN/NOTIFY, so clients connected to any instance receive them.
+func StartRealtimeListener(db *pg.DB) {
+	ln := db.Listen(context.Background(), realtimeChannel)
+	defer ln.Close()
+
+	hub.mu.Lock()
+	hub.db = db
+	hub.mu.Unlock()
+
+	for n := range ln.Channel() {
+		var msg realtimeMessage
+		if err := json.Unmarshal([]byte(n.Payload), &msg); err != nil {
+			log.Printf("Decode Realtime Message Failed: %v", err)
+			continue
+		}
+		if msg.Event != nil {
+			hub.deliver(*msg.Event)
+		}
+		if msg.Revoke != nil {
+			go hub.revoke(db, *msg.Revoke)
+		}
+	}
+}
+
+func publishPostCreated(post *Models.Posts) {
+	hub.publish(forumTopic(post.ForumID), "post.created", post)
+}
+
+func publishCommentCreated(forumID uuid.UUID, comment *Models.Comments) {
+	hub.publish(postTopic(comment.PostID), "comment.created", comment)
+	hub.publish(forumTopic(forumID), "comment.created", gin.H{"post_id": comment.PostID, "comment_id": comment.ID})
+}
+
+func publishVoteCounts(db *pg.DB, forumID uuid.UUID, postID int, commentID *int) {
+	votes := db.Model((*Models.Votes)(nil))
+	if commentID != nil {
+		votes.Where("comment_id = ?", *commentID)
+	} else {
+		votes.Where("post_id = ?", postID)
+	}
+	var up, down int
+	err := votes.
+		ColumnExpr("COALESCE(SUM(CASE WHEN value = 1 THEN 1 ELSE 0 END), 0) AS up").
+		ColumnExpr("COALESCE(SUM(CASE WHEN value = -1 THEN 1 ELSE 0 END), 0) AS down").
+		Select(&up, &down)
+	if err != nil {
+		return
+	}
+	data := gin.H{"post_id": postID, "comment_id": commentID, "upvotes": up, "downvotes": down}
+	hub.publish(postTopic(postID), "vote.updated", data)
+	if commentID == nil {
+		hub.publish(forumTopic(forumID), "vote.updated", data)
+	}
+}
+
+func publishNotification(notification *Models.Notifications) {
+	hub.publish(userTopic(notification.UserID), "notification.created", notification)
+}
+
+func authorizeTopic(db *pg.DB, userID uuid.UUID, kind string, id string) (string, error) {
+	switch kind {
+	case "forum":
+		forumID, err := uuid.Parse(id)
+		if err != nil {
+			return "", fmt.Errorf("invalid forum id %q", id)
+		}
+		allowed, err := canViewForum(db, userID, forumID)
+		if err == pg.ErrNoRows || (err == nil && !allowed) {
+			return "", fmt.Errorf("forum %s not found", id)
+		}
+		if err != nil {
+			return "", err
+		}
+		return forumTopic(forumID), nil
+	case "post":
+		postID, err := strconv.Atoi(id)
+		if err != nil {
+			return "", fmt.Errorf("invalid post id %q", id)
+		}
+		forumID, err := getForumIDByPostID(db, postID)
+		if err == pg.ErrNoRows {
+			return "", fmt.Errorf("post %d not found", postID)
+		}
+		if err != nil {
+			return "", err
+		}
+		allowed, err := canViewForum(db, userID, forumID)
+		if err != nil {
+			return "", err
+		}
+		if !allowed {
+			return "", fmt.Errorf("post %d not found", postID)
+		}
+		return postTopic(postID), nil
+	}
+	return "", fmt.Errorf("unknown subscription %q", kind)
+}
+
+func splitIDs(value string) []string {
+	ids := make([]string, 0)
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func newRealtimeSubscriber(userID uuid.UUID) *realtimeSubscriber {
+	return &realtimeSubscriber{
+		userID:  userID,
+		events:  make(chan realtimeEvent, realtimeBufferSize),
+		dropped: make(chan struct{}),
+	}
+}
+
+func StreamEvents(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	topics := make([]string, 0)
+	for kind, param := range map[string]string{"forum": "forums", "post": "posts"} {
+		for _, id := range splitIDs(c.Query(param)) {
+			topic, err := authorizeTopic(db, userID, kind, id)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription", "detail": err.Error()})
+				return
+			}
+			topics = append(topics, topic)
+		}
+	}
+	if len(topics) > realtimeMaxSubscriptions {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many subscriptions", "detail": fmt.Sprintf("at most %d forums and posts per stream", realtimeMaxSubscriptions)})
+		return
+	}
+
+	s := newRealtimeSubscriber(userID)
+	topics = append(topics, userTopic(userID))
+	for _, topic := range topics {
+		hub.subscribe(s, topic)
+	}
+	defer func() {
+		for _, topic := range topics {
+			hub.unsubscribe(s, topic)
+		}
+	}()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.SSEvent("ready", gin.H{"topics": topics})
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(realtimeHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-s.dropped:
+			c.SSEvent("error", gin.H{"error": "Too many pending events, please reconnect"})
+			c.Writer.Flush()
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		case event := <-s.events:
+			c.SSEvent(event.Type, event)
+			c.Writer.Flush()
+		}
+	}
+}
+
+func checkRealtimeOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range allowedOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+var realtimeUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     checkRealtimeOrigin,
+}
+
+type realtimeCommand struct {
+	Action  string `json:"action"`
+	ForumID string `json:"forum_id"`
+	PostID  int    `json:"post_id"`
+}
+
+func ServeWebSocket(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	conn, err := realtimeUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	s := newRealtimeSubscriber(userID)
+	replies := make(chan interface{}, realtimeBufferSize)
+	reply := func(v interface{}) {
+		select {
+		case replies <- v:
+		default:
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		topics := map[string]bool{userTopic(userID): true}
+		hub.subscribe(s, userTopic(userID))
+		defer func() {
+			for topic := range topics {
+				hub.unsubscribe(s, topic)
+			}
+		}()
+
+		conn.SetReadLimit(4096)
+		conn.SetReadDeadline(time.Now().Add(2 * realtimeHeartbeat))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * realtimeHeartbeat))
+		})
+		for {
+			var cmd realtimeCommand
+			if err := conn.ReadJSON(&cmd); err != nil {
+				return
+			}
+			if cmd.Action != "subscribe" && cmd.Action != "unsubscribe" {
+				reply(gin.H{"type": "error", "error": "Action must be 'subscribe' or 'unsubscribe'"})
+				continue
+			}
+			kind, id := "forum", cmd.ForumID
+			if cmd.PostID != 0 {
+				kind, id = "post", strconv.Itoa(cmd.PostID)
+			}
+			topic, err := authorizeTopic(db, userID, kind, id)
+			if err != nil {
+				reply(gin.H{"type": "error", "error": err.Error()})
+				continue
+			}
+
+			if cmd.Action == "unsubscribe" {
+				delete(topics, topic)
+				hub.unsubscribe(s, topic)
+				reply(gin.H{"type": "unsubscribed", "topic": topic})
+				continue
+			}
+			if !topics[topic] && len(topics) > realtimeMaxSubscriptions {
+				reply(gin.H{"type": "error", "error": "Too many subscriptions"})
+				continue
+			}
+			topics[topic] = true
+			hub.subscribe(s, topic)
+			reply(gin.H{"type": "subscribed", "topic": topic})
+		}
+	}()
+
+	writeRealtimeEvents(conn, s, replies, done)
+}
+
+func writeRealtimeEvents(conn *websocket.Conn, s *realtimeSubscriber, replies <-chan interface{}, done <-chan struct{}) {
+	heartbeat := time.NewTicker(realtimeHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case <-s.dropped:
+			conn.SetWriteDeadline(time.Now().Add(realtimeWriteTimeout))
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many pending events"))
+			return
+		case <-heartbeat.C:
+			conn.SetWriteDeadline(time.Now().Add(realtimeWriteTimeout))
+			err = conn.WriteMessage(websocket.PingMessage, nil)
+		case v := <-replies:
+			conn.SetWriteDeadline(time.Now().Add(realtimeWriteTimeout))
+			err = conn.WriteJSON(v)
+		case event := <-s.events:
+			conn.SetWriteDeadline(time.Now().Add(realtimeWriteTimeout))
+			err = conn.WriteJSON(event)
+			if err == nil && event.Type == "subscription.revoked" && strings.HasPrefix(event.Topic, "chat:") {
+				// A chat connection has no other topic, so it ends here.
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "chat access revoked"))
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,455 @@
+package Handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+var reportCategories = map[string]bool{
+	"spam":                true,
+	"harassment":          true,
+	"hate_speech":         true,
+	"nsfw":                true,
+	"misinformation":      true,
+	"academic_dishonesty": true,
+	"off_topic":           true,
+	"other":               true,
+}
+
+func CreateReport(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var payload struct {
+		TargetType string    `json:"target_type"`
+		PostID     int       `json:"post_id"`
+		CommentID  int       `json:"comment_id"`
+		UserID     uuid.UUID `json:"user_id"`
+		Category   string    `json:"category"`
+		Reason     string    `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+
+	category := strings.ToLower(strings.TrimSpace(payload.Category))
+	if !reportCategories[category] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report category"})
+		return
+	}
+
+	report := &Models.Reports{
+		ReporterID: userID,
+		TargetType: strings.ToLower(strings.TrimSpace(payload.TargetType)),
+		Category:   category,
+		Reason:     strings.TrimSpace(payload.Reason),
+		Status:     "open",
+		CreatedAt:  time.Now(),
+	}
+
+	var authorID uuid.UUID
+	switch report.TargetType {
+	case "post":
+		var post Models.Posts
+		if err := db.Model(&post).Where("id = ?", payload.PostID).Select(); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+			return
+		}
+		report.PostID = &post.ID
+		report.ForumID = &post.ForumID
+		report.ReportedUserID = &post.UserID
+		report.ContentSnapshot = post.Title + "\n\n" + post.Body
+		authorID = post.UserID
+	case "comment":
+		var comment Models.Comments
+		if err := db.Model(&comment).Where("id = ?", payload.CommentID).Select(); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+			return
+		}
+		forumID, err := getForumIDByPostID(db, comment.PostID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+			return
+		}
+		report.CommentID = &comment.ID
+		report.ForumID = &forumID
+		report.ReportedUserID = &comment.UserID
+		report.ContentSnapshot = comment.Body
+		authorID = comment.UserID
+	case "user":
+		var user Models.Users
+		if err := db.Model(&user).Where("uid = ?", payload.UserID).Select(); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		report.ReportedUserID = &user.UID
+		report.ContentSnapshot = user.Username
+		authorID = user.UID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Target type must be 'post', 'comment' or 'user'"})
+		return
+	}
+
+	if authorID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot report yourself"})
+		return
+	}
+
+	duplicate := db.Model((*Models.Reports)(nil)).
+		Where("reporter_id = ?", userID).
+		Where("status = ?", "open").
+		Where("target_type = ?", report.TargetType)
+	switch report.TargetType {
+	case "post":
+		duplicate.Where("post_id = ?", *report.PostID)
+	case "comment":
+		duplicate.Where("comment_id = ?", *report.CommentID)
+	case "user":
+		duplicate.Where("reported_user_id = ?", *report.ReportedUserID)
+	}
+	exists, err := duplicate.Exists()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reported this"})
+		return
+	}
+
+	if _, err := db.Model(report).Insert(); err != nil {
+		log.Printf("Create Report Failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create report", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Report submitted successfully",
+		"report":  report,
+	})
+}
+
+func GetReports(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	isSysAdmin, err := isSystemAdmin(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !isSysAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "Only system admins can view the site-wide moderation queue",
+		})
+		return
+	}
+
+	reports := make([]Models.Reports, 0)
+	query := db.Model(&reports).Relation("Reporter.uid").Relation("Reporter.username").Order("reports.created_at ASC")
+	applyReportFilters(c, query)
+	if err := query.Select(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reports", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+func GetForumReports(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to view reports in this forum",
+		})
+		return
+	}
+
+	reports := make([]Models.Reports, 0)
+	query := db.Model(&reports).
+		Relation("Reporter.uid").
+		Relation("Reporter.username").
+		Where("reports.forum_id = ?", forumID).
+		Order("reports.created_at ASC")
+	applyReportFilters(c, query)
+	if err := query.Select(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reports", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+func applyReportFilters(c *gin.Context, query *pg.Query) {
+	status := c.DefaultQuery("status", "open")
+	if status != "all" {
+		query.Where("reports.status = ?", status)
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query.Where("reports.target_type = ?", targetType)
+	}
+	if category := c.Query("category"); category != "" {
+		query.Where("reports.category = ?", category)
+	}
+}
+
+func ResolveReport(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	reportID, err := strconv.Atoi(c.Param("report_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Report ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var report Models.Reports
+	if err := db.Model(&report).Where("id = ?", reportID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	isSysAdmin, err := isSystemAdmin(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	hasAccess := isSysAdmin
+	if !hasAccess && report.ForumID != nil {
+		hasAccess = isForumAdmin(db, userID, *report.ForumID)
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to resolve this report",
+		})
+		return
+	}
+
+	if report.Status != "open" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Report has already been resolved"})
+		return
+	}
+
+	var payload struct {
+		Action          string `json:"action"`
+		Note            string `json:"note"`
+		DurationMinutes int    `json:"duration_minutes"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+	action := strings.ToLower(strings.TrimSpace(payload.Action))
+	if payload.DurationMinutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Duration must not be negative"})
+		return
+	}
+
+	var siblingIDs []int
+	sibling := db.Model((*Models.Reports)(nil)).
+		Column("id").
+		Where("status = ?", "open").
+		Where("target_type = ?", report.TargetType)
+	switch report.TargetType {
+	case "post":
+		sibling.Where("post_id = ?", report.PostID)
+	case "comment":
+		sibling.Where("comment_id = ?", report.CommentID)
+	default:
+		sibling.Where("reported_user_id = ?", report.ReportedUserID)
+	}
+	if err := sibling.Select(&siblingIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	siblingIDs = append(siblingIDs, report.ID)
+
+	status := "resolved"
+	switch action {
+	case "dismiss":
+		status = "dismissed"
+	case "remove":
+		err = removeReportedContent(db, ch, userID, &report)
+	case "lock":
+		err = lockReportedPost(db, ch, &report)
+	case "ban_author":
+		err = banReportedAuthor(c, db, userID, &report, payload.Note, payload.DurationMinutes)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Action must be 'dismiss', 'remove', 'lock' or 'ban_author'"})
+		return
+	}
+	if err != nil {
+		if reportErr, ok := err.(*reportActionError); ok {
+			c.JSON(reportErr.status, gin.H{"error": reportErr.message})
+			return
+		}
+		log.Printf("Resolve Report Failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply moderation action", "detail": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	_, err = db.Model((*Models.Reports)(nil)).
+		Set("status = ?", status).
+		Set("action = ?", action).
+		Set("resolution_note = ?", strings.TrimSpace(payload.Note)).
+		Set("resolved_by = ?", userID).
+		Set("resolved_at = ?", now).
+		Where("id IN (?)", pg.In(siblingIDs)).
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record resolution", "detail": err.Error()})
+		return
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    report.ForumID,
+		Action:     "report." + action,
+		TargetType: "report",
+		TargetID:   strconv.Itoa(report.ID),
+		Before:     report,
+		After:      gin.H{"status": status, "action": action, "resolved_reports": siblingIDs},
+		Reason:     strings.TrimSpace(payload.Note),
+	})
+	if action != "dismiss" && report.ReportedUserID != nil && report.ForumID != nil {
+		notifyModerationAction(db, userID, *report.ReportedUserID, *report.ForumID, report.PostID, report.CommentID, "report."+action, strings.TrimSpace(payload.Note))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Report resolved",
+		"status":           status,
+		"action":           action,
+		"resolved_reports": len(siblingIDs),
+	})
+}
+
+type reportActionError struct {
+	status  int
+	message string
+}
+
+func (e *reportActionError) Error() string {
+	return e.message
+}
+
+func removeReportedContent(db *pg.DB, ch *cache.Cache, moderatorID uuid.UUID, report *Models.Reports) error {
+	switch report.TargetType {
+	case "post":
+		var post Models.Posts
+		if err := db.Model(&post).Where("id = ?", report.PostID).Select(); err != nil {
+			return &reportActionError{http.StatusNotFound, "Post not found or already removed"}
+		}
+		return removePost(db, ch, &post, moderatorID)
+	case "comment":
+		var comment Models.Comments
+		if err := db.Model(&comment).Where("id = ?", report.CommentID).Select(); err != nil {
+			return &reportActionError{http.StatusNotFound, "Comment not found or already removed"}
+		}
+		return removeComment(db, ch, &comment, moderatorID)
+	}
+	return &reportActionError{http.StatusBadRequest, "Only posts and comments can be removed"}
+}
+
+func rejectLockedPost(c *gin.Context, post *Models.Posts, detail string) bool {
+	if !post.IsLocked {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Post is locked", "detail": detail})
+	return true
+}
+
+func lockReportedPost(db *pg.DB, ch *cache.Cache, report *Models.Reports) error {
+	var postID int
+	switch report.TargetType {
+	case "post":
+		postID = *report.PostID
+	case "comment":
+		var comment Models.Comments
+		if err := db.Model(&comment).Column("post_id").Where("id = ?", report.CommentID).Select(); err != nil {
+			return &reportActionError{http.StatusNotFound, "Comment not found or already removed"}
+		}
+		postID = comment.PostID
+	default:
+		return &reportActionError{http.StatusBadRequest, "Only posts and comments can be locked"}
+	}
+
+	var post Models.Posts
+	if err := db.Model(&post).Where("id = ?", postID).Select(); err != nil {
+		return &reportActionError{http.StatusNotFound, "Post not found or already removed"}
+	}
+	return setPostLocked(db, ch, &post, true)
+}
+
+func banReportedAuthor(c *gin.Context, db *pg.DB, moderatorID uuid.UUID, report *Models.Reports, note string, durationMinutes int) error {
+	if report.ForumID == nil || report.ReportedUserID == nil {
+		return &reportActionError{http.StatusBadRequest, "Only authors of forum content can be banned"}
+	}
+
+	authorIsAdmin, err := canModerateForum(db, *report.ReportedUserID, *report.ForumID)
+	if err != nil {
+		return &reportActionError{http.StatusNotFound, "Author not found"}
+	}
+	if authorIsAdmin {
+		return &reportActionError{http.StatusForbidden, "Forum admins cannot be banned"}
+	}
+
+	reason := strings.TrimSpace(note)
+	if reason == "" {
+		reason = "Reported for " + report.Category
+	}
+	ban := &Models.ForumBans{
+		ForumID:   *report.ForumID,
+		UserID:    *report.ReportedUserID,
+		Type:      "ban",
+		Reason:    reason,
+		BannedBy:  moderatorID,
+		CreatedAt: time.Now(),
+	}
+	if durationMinutes > 0 {
+		expiresAt := time.Now().Add(time.Duration(durationMinutes) * time.Minute)
+		ban.ExpiresAt = &expiresAt
+	}
+
+	return db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		return upsertForumBan(tx, ban)
+	})
+}
@@ -0,0 +1,390 @@
+package Handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+type diffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Bodies whose changed region would need a larger LCS table are diffed as
+// one replaced block instead.
+const maxDiffCells = 1000000
+
+func diffLines(from string, to string) []diffLine {
+	a := strings.Split(from, "\n")
+	b := strings.Split(to, "\n")
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	result := make([]diffLine, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		result = append(result, diffLine{"equal", line})
+	}
+	result = append(result, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		result = append(result, diffLine{"equal", line})
+	}
+	return result
+}
+
+func diffMiddle(a []string, b []string) []diffLine {
+	result := make([]diffLine, 0, len(a)+len(b))
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		for _, line := range a {
+			result = append(result, diffLine{"delete", line})
+		}
+		for _, line := range b {
+			result = append(result, diffLine{"insert", line})
+		}
+		return result
+	}
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, diffLine{"equal", a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, diffLine{"delete", a[i]})
+			i++
+		default:
+			result = append(result, diffLine{"insert", b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, diffLine{"delete", a[i]})
+	}
+	for ; j < len(b); j++ {
+		result = append(result, diffLine{"insert", b[j]})
+	}
+	return result
+}
+
+func savePostRevision(tx *pg.Tx, post *Models.Posts, title string, body string, editorID uuid.UUID, editedAt time.Time) error {
+	// Concurrent edits of the same post wait here, so each gets the next number.
+	var locked Models.Posts
+	err := tx.Model(&locked).Column("id").Where("id = ?", post.ID).For("UPDATE").Select()
+	if err != nil {
+		return err
+	}
+	var count int
+	err = tx.Model((*Models.PostRevisions)(nil)).
+		ColumnExpr("COALESCE(MAX(revision), 0)").
+		Where("post_id = ?", post.ID).
+		Select(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		original := &Models.PostRevisions{
+			PostID:    post.ID,
+			Revision:  1,
+			Title:     post.Title,
+			Body:      post.Body,
+			EditedBy:  post.UserID,
+			CreatedAt: post.CreatedAt,
+		}
+		if _, err := tx.Model(original).Insert(); err != nil {
+			return err
+		}
+		count = 1
+	}
+
+	revision := &Models.PostRevisions{
+		PostID:    post.ID,
+		Revision:  count + 1,
+		Title:     title,
+		Body:      body,
+		EditedBy:  editorID,
+		CreatedAt: editedAt,
+	}
+	_, err = tx.Model(revision).Insert()
+	return err
+}
+
+func saveCommentRevision(tx *pg.Tx, comment *Models.Comments, body string, editorID uuid.UUID, editedAt time.Time) error {
+	var locked Models.Comments
+	err := tx.Model(&locked).Column("id").Where("id = ?", comment.ID).For("UPDATE").Select()
+	if err != nil {
+		return err
+	}
+	var count int
+	err = tx.Model((*Models.CommentRevisions)(nil)).
+		ColumnExpr("COALESCE(MAX(revision), 0)").
+		Where("comment_id = ?", comment.ID).
+		Select(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		original := &Models.CommentRevisions{
+			CommentID: comment.ID,
+			Revision:  1,
+			Body:      comment.Body,
+			EditedBy:  comment.UserID,
+			CreatedAt: comment.CreatedAt,
+		}
+		if _, err := tx.Model(original).Insert(); err != nil {
+			return err
+		}
+		count = 1
+	}
+
+	revision := &Models.CommentRevisions{
+		CommentID: comment.ID,
+		Revision:  count + 1,
+		Body:      body,
+		EditedBy:  editorID,
+		CreatedAt: editedAt,
+	}
+	_, err = tx.Model(revision).Insert()
+	return err
+}
+
+func getPostRevisionList(db *pg.DB, post *Models.Posts) ([]Models.PostRevisions, error) {
+	revisions := make([]Models.PostRevisions, 0)
+	err := db.Model(&revisions).Where("post_id = ?", post.ID).Order("revision ASC").Select()
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		revisions = append(revisions, Models.PostRevisions{
+			PostID:    post.ID,
+			Revision:  1,
+			Title:     post.Title,
+			Body:      post.Body,
+			EditedBy:  post.UserID,
+			CreatedAt: post.CreatedAt,
+		})
+	}
+	return revisions, nil
+}
+
+func getCommentRevisionList(db *pg.DB, comment *Models.Comments) ([]Models.CommentRevisions, error) {
+	revisions := make([]Models.CommentRevisions, 0)
+	err := db.Model(&revisions).Where("comment_id = ?", comment.ID).Order("revision ASC").Select()
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		revisions = append(revisions, Models.CommentRevisions{
+			CommentID: comment.ID,
+			Revision:  1,
+			Body:      comment.Body,
+			EditedBy:  comment.UserID,
+			CreatedAt: comment.CreatedAt,
+		})
+	}
+	return revisions, nil
+}
+
+func parseRevisionRange(c *gin.Context, latest int) (int, int, bool) {
+	to, err := strconv.Atoi(c.DefaultQuery("to", strconv.Itoa(latest)))
+	if err != nil || to < 1 || to > latest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' revision"})
+		return 0, 0, false
+	}
+	defaultFrom := to - 1
+	if defaultFrom < 1 {
+		defaultFrom = 1
+	}
+	from, err := strconv.Atoi(c.DefaultQuery("from", strconv.Itoa(defaultFrom)))
+	if err != nil || from < 1 || from > latest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' revision"})
+		return 0, 0, false
+	}
+	return from, to, true
+}
+
+func GetPostRevisions(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	postID, err := strconv.Atoi(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var post Models.Posts
+	if err := db.Model(&post).Where("id = ?", postID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if rejectPrivateForum(c, db, userID, post.ForumID) {
+		return
+	}
+
+	revisions, err := getPostRevisionList(db, &post)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+func GetPostRevisionDiff(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	postID, err := strconv.Atoi(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var post Models.Posts
+	if err := db.Model(&post).Where("id = ?", postID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if rejectPrivateForum(c, db, userID, post.ForumID) {
+		return
+	}
+
+	revisions, err := getPostRevisionList(db, &post)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve revisions"})
+		return
+	}
+
+	from, to, ok := parseRevisionRange(c, len(revisions))
+	if !ok {
+		return
+	}
+	fromRev, toRev := revisions[from-1], revisions[to-1]
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":  fromRev,
+		"to":    toRev,
+		"title": diffLines(fromRev.Title, toRev.Title),
+		"body":  diffLines(fromRev.Body, toRev.Body),
+	})
+}
+
+func GetCommentRevisions(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	commentID, err := strconv.Atoi(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var comment Models.Comments
+	if err := db.Model(&comment).Where("id = ?", commentID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	forumID, err := getForumIDByPostID(db, comment.PostID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if rejectPrivateForum(c, db, userID, forumID) {
+		return
+	}
+
+	revisions, err := getCommentRevisionList(db, &comment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+func GetCommentRevisionDiff(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	commentID, err := strconv.Atoi(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var comment Models.Comments
+	if err := db.Model(&comment).Where("id = ?", commentID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	forumID, err := getForumIDByPostID(db, comment.PostID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if rejectPrivateForum(c, db, userID, forumID) {
+		return
+	}
+
+	revisions, err := getCommentRevisionList(db, &comment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve revisions"})
+		return
+	}
+
+	from, to, ok := parseRevisionRange(c, len(revisions))
+	if !ok {
+		return
+	}
+	fromRev, toRev := revisions[from-1], revisions[to-1]
+
+	c.JSON(http.StatusOK, gin.H{
+		"from": fromRev,
+		"to":   toRev,
+		"body": diffLines(fromRev.Body, toRev.Body),
+	})
+}
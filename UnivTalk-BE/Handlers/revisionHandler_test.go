@@ -0,0 +1,87 @@
+package Handlers
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []diffLine
+	}{
+		{
+			name: "unchanged",
+			from: "a\nb",
+			to:   "a\nb",
+			want: []diffLine{{"equal", "a"}, {"equal", "b"}},
+		},
+		{
+			name: "appended line",
+			from: "a",
+			to:   "a\nb",
+			want: []diffLine{{"equal", "a"}, {"insert", "b"}},
+		},
+		{
+			name: "removed line",
+			from: "a\nb\nc",
+			to:   "a\nc",
+			want: []diffLine{{"equal", "a"}, {"delete", "b"}, {"equal", "c"}},
+		},
+		{
+			name: "replaced line",
+			from: "a\nb\nc",
+			to:   "a\nx\nc",
+			want: []diffLine{{"equal", "a"}, {"delete", "b"}, {"insert", "x"}, {"equal", "c"}},
+		},
+		{
+			name: "common line inside the changed region",
+			from: "a\nb\nc\nd",
+			to:   "a\nc\nx\nd",
+			want: []diffLine{{"equal", "a"}, {"delete", "b"}, {"equal", "c"}, {"insert", "x"}, {"equal", "d"}},
+		},
+		{
+			name: "everything replaced",
+			from: "a\nb",
+			to:   "x",
+			want: []diffLine{{"delete", "a"}, {"delete", "b"}, {"insert", "x"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffLines(tt.from, tt.to)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("diffLines(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesLargeChangeFallsBackToReplace(t *testing.T) {
+	var from, to []string
+	for i := 0; i < 1001; i++ {
+		from = append(from, "old "+strconv.Itoa(i))
+		to = append(to, "new "+strconv.Itoa(i))
+	}
+	got := diffLines("title\n"+strings.Join(from, "\n"), "title\n"+strings.Join(to, "\n"))
+
+	if len(got) != 1+len(from)+len(to) {
+		t.Fatalf("got %d lines, want %d", len(got), 1+len(from)+len(to))
+	}
+	if got[0] != (diffLine{"equal", "title"}) {
+		t.Errorf("first line = %v, want the shared prefix", got[0])
+	}
+	for i, line := range got[1:] {
+		want := "delete"
+		if i >= len(from) {
+			want = "insert"
+		}
+		if line.Op != want {
+			t.Fatalf("line %d op = %q, want %q", i+1, line.Op, want)
+		}
+	}
+}
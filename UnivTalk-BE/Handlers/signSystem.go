@@ -219,6 +219,12 @@ func GetProfile(c *gin.Context, db *pg.DB) {
 		return
 	}
 
+	storage, err := userStorageUsage(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve storage usage"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"user_id":    user.UID,
 		"username":   user.Username,
@@ -228,6 +234,7 @@ func GetProfile(c *gin.Context, db *pg.DB) {
 		"university": user.University,
 		"status":     user.Status,
 		"is_admin":   user.IsAdmin,
+		"storage":    storage,
 	})
 }
 
@@ -0,0 +1,439 @@
+package Handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/Ariffansyah/UnivTalk/Storage"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const signedMediaTTL = 1 * time.Hour
+
+var (
+	mediaStorage    Storage.Backend
+	relocationQueue = make(chan uuid.UUID, 64)
+)
+
+func SetMediaStorage(backend Storage.Backend) {
+	mediaStorage = backend
+}
+
+func mediaKey(private bool, name string) string {
+	if private {
+		return Storage.PrivatePrefix + name
+	}
+	return name
+}
+
+func resolveMediaURL(key string, canViewPrivate bool) string {
+	if !Storage.IsPrivateKey(key) {
+		return mediaStorage.URL(key)
+	}
+	if !canViewPrivate {
+		return ""
+	}
+	signed, err := mediaStorage.SignedURL(context.Background(), key, signedMediaTTL)
+	if err != nil {
+		log.Printf("Sign Media URL Failed (%s): %v", key, err)
+		return ""
+	}
+	return signed
+}
+
+func resolveAttachmentURLs(attachments []Models.PostAttachments, canViewPrivate bool) {
+	for i := range attachments {
+		a := &attachments[i]
+		a.URL = resolveMediaURL(a.StorageKey, canViewPrivate)
+		a.ThumbnailURL = ""
+		for j := range a.Variants {
+			v := &a.Variants[j]
+			v.URL = resolveMediaURL(v.StorageKey, canViewPrivate)
+			if v.Kind == "thumbnail" && v.Format != "webp" {
+				a.ThumbnailURL = v.URL
+			}
+		}
+	}
+}
+
+func attachmentKeys(attachments []Models.PostAttachments) []string {
+	keys := make([]string, 0, len(attachments))
+	for _, a := range attachments {
+		keys = append(keys, a.StorageKey)
+		for _, v := range a.Variants {
+			keys = append(keys, v.StorageKey)
+		}
+	}
+	return keys
+}
+
+func deleteStoredMedia(keys []string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if err := mediaStorage.Delete(context.Background(), key); err != nil {
+			log.Printf("Delete Stored Media Failed (%s): %v", key, err)
+		}
+	}
+}
+
+func isPrivateForum(db *pg.DB, forumID uuid.UUID) (bool, error) {
+	var forum Models.Forums
+	err := db.Model(&forum).Column("is_private").Where("fid = ?", forumID).Select()
+	return forum.IsPrivate, err
+}
+
+func canViewForum(db *pg.DB, userID uuid.UUID, forumID uuid.UUID) (bool, error) {
+	privateForum, err := isPrivateForum(db, forumID)
+	if err != nil || !privateForum {
+		return err == nil, err
+	}
+	isMember, err := db.Model((*Models.ForumMembers)(nil)).
+		Where("forum_id = ?", forumID).
+		Where("user_id = ?", userID).
+		Exists()
+	if err != nil || isMember {
+		return isMember, err
+	}
+	return isSystemAdmin(db, userID)
+}
+
+func visiblePostsCondition(db *pg.DB, userID uuid.UUID) (string, []interface{}) {
+	if isSysAdmin, _ := isSystemAdmin(db, userID); isSysAdmin {
+		return "TRUE", nil
+	}
+	return "posts.forum_id IN (SELECT fid FROM forums WHERE is_private = FALSE) OR posts.forum_id IN (SELECT forum_id FROM forum_members WHERE user_id = ?)",
+		[]interface{}{userID}
+}
+
+func rejectPrivateForum(c *gin.Context, db *pg.DB, userID uuid.UUID, forumID uuid.UUID) bool {
+	allowed, err := canViewForum(db, userID, forumID)
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forum not found"})
+		return true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum access"})
+		return true
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "Only members can access this private forum",
+		})
+		return true
+	}
+	return false
+}
+
+func privateMediaAccess(db *pg.DB, userID uuid.UUID, forumIDs []uuid.UUID) map[uuid.UUID]bool {
+	access := make(map[uuid.UUID]bool, len(forumIDs))
+	if userID == uuid.Nil || len(forumIDs) == 0 {
+		return access
+	}
+	if isSysAdmin, _ := isSystemAdmin(db, userID); isSysAdmin {
+		for _, id := range forumIDs {
+			access[id] = true
+		}
+		return access
+	}
+
+	var memberships []Models.ForumMembers
+	err := db.Model(&memberships).
+		Where("user_id = ?", userID).
+		Where("forum_id IN (?)", pg.In(forumIDs)).
+		Select()
+	if err != nil {
+		return access
+	}
+	for _, m := range memberships {
+		access[m.ForumID] = true
+	}
+	return access
+}
+
+func mediaKeysForPosts(db *pg.DB, postIDs []int) ([]string, error) {
+	keys := make([]string, 0)
+	if len(postIDs) == 0 {
+		return keys, nil
+	}
+	_, err := db.Query(&keys, `
+		SELECT a.storage_key FROM post_attachments a WHERE a.post_id IN (?)
+		UNION ALL
+		SELECT v.storage_key FROM attachment_variants v
+		JOIN post_attachments a ON a.id = v.attachment_id
+		WHERE a.post_id IN (?)
+	`, pg.In(postIDs), pg.In(postIDs))
+	return keys, err
+}
+
+func ServeSignedMedia(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	local, ok := mediaStorage.(*Storage.Local)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if !local.Verify(key, c.Query("expires"), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired link"})
+		return
+	}
+	path, err := local.Path(key)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file"})
+		return
+	}
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.File(path)
+}
+
+func SetForumPrivacy(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to change this forum's privacy",
+		})
+		return
+	}
+
+	var payload struct {
+		IsPrivate *bool `json:"is_private"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil || payload.IsPrivate == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "is_private is required"})
+		return
+	}
+
+	var forum Models.Forums
+	if err := db.Model(&forum).Where("fid = ?", forumID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forum not found"})
+		return
+	}
+	if forum.IsPrivate == *payload.IsPrivate {
+		c.JSON(http.StatusOK, gin.H{"message": "No change", "is_private": forum.IsPrivate})
+		return
+	}
+
+	_, err = db.Model((*Models.Forums)(nil)).
+		Set("is_private = ?", *payload.IsPrivate).
+		Set("media_relocation_status = 'pending'").
+		Set("media_relocation_error = ''").
+		Set("updated_at = ?", time.Now()).
+		Where("fid = ?", forumID).
+		Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update forum"})
+		return
+	}
+
+	ch.Delete("forums_all")
+	ch.Delete(fmt.Sprintf("forum_%s", forumID.String()))
+	if *payload.IsPrivate {
+		revokeForumSubscriptions(db, forumID, uuid.Nil)
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "forum.privacy",
+		TargetType: "forum",
+		TargetID:   forumID.String(),
+		Before:     gin.H{"is_private": forum.IsPrivate},
+		After:      gin.H{"is_private": *payload.IsPrivate},
+	})
+
+	select {
+	case relocationQueue <- forumID:
+	default:
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Forum privacy updated", "is_private": *payload.IsPrivate, "media_relocation_status": "pending"})
+}
+
+func StartMediaRelocationWorker(db *pg.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case forumID := <-relocationQueue:
+			runForumRelocation(db, forumID)
+		case <-ticker.C:
+			// Picks up relocations interrupted by a restart and retries failed ones.
+			var forumIDs []uuid.UUID
+			err := db.Model((*Models.Forums)(nil)).
+				Column("fid").
+				Where("media_relocation_status IN ('pending', 'failed')").
+				Select(&forumIDs)
+			if err != nil {
+				log.Printf("Relocate Forum Media Job Failed: %v", err)
+				continue
+			}
+			for _, forumID := range forumIDs {
+				runForumRelocation(db, forumID)
+			}
+		}
+	}
+}
+
+func runForumRelocation(db *pg.DB, forumID uuid.UUID) {
+	private, err := isPrivateForum(db, forumID)
+	if err != nil {
+		log.Printf("Relocate Forum Media Failed (%s): %v", forumID, err)
+		return
+	}
+
+	status, message := "done", ""
+	failed, err := relocateForumMedia(db, forumID, private)
+	if err != nil {
+		status, message = "failed", fmt.Sprintf("%d files could not be moved: %v", failed, err)
+	}
+	// A privacy change made while this run was going leaves the forum pending.
+	_, err = db.Model((*Models.Forums)(nil)).
+		Set("media_relocation_status = ?", status).
+		Set("media_relocation_error = ?", message).
+		Where("fid = ?", forumID).
+		Where("is_private = ?", private).
+		Update()
+	if err != nil {
+		log.Printf("Relocate Forum Media Failed (%s): %v", forumID, err)
+	}
+}
+
+func relocateForumMedia(db *pg.DB, forumID uuid.UUID, private bool) (int, error) {
+	var attachments []Models.PostAttachments
+	err := db.Model(&attachments).
+		Where("post_id IN (SELECT id FROM posts WHERE forum_id = ?)", forumID).
+		Select()
+	if err != nil {
+		return 0, err
+	}
+	loaded := map[int][]Models.PostAttachments{0: attachments}
+	if err := attachVariants(db, loaded); err != nil {
+		return 0, err
+	}
+
+	moved, failed := 0, 0
+	var lastErr error
+	fail := func(key string, err error) {
+		log.Printf("Relocate Forum Media Failed (%s): %v", key, err)
+		failed++
+		lastErr = err
+	}
+	for _, a := range loaded[0] {
+		for _, v := range a.Variants {
+			newKey, err := relocateMediaObject(v.StorageKey, v.SizeBytes, private, "image/"+v.Format)
+			if err != nil {
+				fail(v.StorageKey, err)
+				continue
+			}
+			if newKey == v.StorageKey {
+				continue
+			}
+			_, err = db.Model((*Models.AttachmentVariants)(nil)).
+				Set("storage_key = ?", newKey).
+				Set("url = ?", publicURLForKey(newKey)).
+				Where("id = ?", v.ID).
+				Update()
+			if err != nil {
+				fail(v.StorageKey, err)
+				continue
+			}
+			deleteStoredMedia([]string{v.StorageKey})
+		}
+
+		newKey, err := relocateMediaObject(a.StorageKey, a.SizeBytes, private, a.MimeType)
+		if err != nil {
+			fail(a.StorageKey, err)
+			continue
+		}
+		if newKey == a.StorageKey {
+			continue
+		}
+		_, err = db.Model((*Models.PostAttachments)(nil)).
+			Set("storage_key = ?", newKey).
+			Set("url = ?", publicURLForKey(newKey)).
+			Where("id = ?", a.ID).
+			Update()
+		if err != nil {
+			fail(a.StorageKey, err)
+			continue
+		}
+		deleteStoredMedia([]string{a.StorageKey})
+		if a.Position == 1 {
+			_, err = db.Model((*Models.Posts)(nil)).
+				Set("media_url = ?", publicURLForKey(newKey)).
+				Where("id = ?", a.PostID).
+				Update()
+			if err != nil {
+				log.Printf("Relocate Forum Media Failed (post %d): %v", a.PostID, err)
+			}
+		}
+		moved++
+	}
+	log.Printf("Relocate Forum Media: moved %d attachments of forum %s", moved, forumID)
+	return failed, lastErr
+}
+
+func relocateMediaObject(key string, size int64, private bool, contentType string) (string, error) {
+	name := strings.TrimPrefix(key, Storage.PrivatePrefix)
+	newKey := mediaKey(private, name)
+	if newKey == key {
+		return key, nil
+	}
+
+	ctx := context.Background()
+	src, err := mediaStorage.Get(ctx, key)
+	if err == Storage.ErrNotFound {
+		// The file is already gone, so retrying would never succeed.
+		log.Printf("Relocate Forum Media: %s is missing, skipping", key)
+		return key, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	if size <= 0 {
+		size = -1
+	}
+	if err := mediaStorage.Put(ctx, newKey, src, size, contentType); err != nil {
+		return "", err
+	}
+	return newKey, nil
+}
+
+func publicURLForKey(key string) string {
+	if Storage.IsPrivateKey(key) {
+		return ""
+	}
+	return mediaStorage.URL(key)
+}
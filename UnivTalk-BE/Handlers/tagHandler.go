@@ -0,0 +1,644 @@
+package Handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	maxTagsPerPost   = 10
+	maxTagLength     = 50
+	maxCuratedTags   = 100
+	tagSuggestLimit  = 10
+	trendingTagLimit = 20
+)
+
+var (
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\w&#/(])#([\p{L}\p{N}_][\p{L}\p{N}_-]{0,49})`)
+	tagNamePattern = regexp.MustCompile(`^[\p{L}\p{N}_][\p{L}\p{N}_-]*$`)
+	tagDigitsOnly  = regexp.MustCompile(`^[0-9]+$`)
+)
+
+type tagSelection struct {
+	Name   string
+	Source string
+}
+
+type tagWithCount struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"`
+}
+
+type tagRejectedError struct {
+	Names []string
+}
+
+func (e *tagRejectedError) Error() string {
+	return fmt.Sprintf("tags not allowed in this forum: %s", strings.Join(e.Names, ", "))
+}
+
+func normalizeTag(raw string) (string, bool) {
+	name := strings.ToLower(strings.TrimSpace(raw))
+	name = strings.TrimRight(strings.TrimPrefix(name, "#"), "-")
+	if name == "" || utf8.RuneCountInString(name) > maxTagLength {
+		return "", false
+	}
+	if !tagNamePattern.MatchString(name) || tagDigitsOnly.MatchString(name) {
+		return "", false
+	}
+	return name, true
+}
+
+func parseHashtags(body string) []string {
+	body = mentionCodePattern.ReplaceAllString(body, " ")
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		name, ok := normalizeTag(match[1])
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+		if len(names) == maxTagsPerPost {
+			break
+		}
+	}
+	return names
+}
+
+func parseTagInput(values []string) ([]string, error) {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, value := range values {
+		for _, raw := range strings.Split(value, ",") {
+			if strings.TrimSpace(raw) == "" {
+				continue
+			}
+			name, ok := normalizeTag(raw)
+			if !ok {
+				return nil, fmt.Errorf("invalid tag %q", strings.TrimSpace(raw))
+			}
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) > maxTagsPerPost {
+		return nil, fmt.Errorf("a post can have at most %d tags", maxTagsPerPost)
+	}
+	return names, nil
+}
+
+func forumTagPolicy(db *pg.DB, forumID uuid.UUID) (string, error) {
+	var forum Models.Forums
+	err := db.Model(&forum).Column("tag_policy").Where("fid = ?", forumID).Select()
+	return forum.TagPolicy, err
+}
+
+func curatedTagNames(db *pg.DB, forumID uuid.UUID) ([]string, error) {
+	names := make([]string, 0)
+	err := db.Model((*Models.Tags)(nil)).
+		Column("tags.name").
+		Where("tags.id IN (SELECT tag_id FROM forum_tags WHERE forum_id = ?)", forumID).
+		Order("tags.name ASC").
+		Select(&names)
+	return names, err
+}
+
+func resolvePostTags(db *pg.DB, forumID uuid.UUID, manual []string, checkManual bool, body string) ([]tagSelection, error) {
+	hashtags := parseHashtags(body)
+	if len(manual) == 0 && len(hashtags) == 0 {
+		return nil, nil
+	}
+
+	policy, err := forumTagPolicy(db, forumID)
+	if err != nil {
+		return nil, err
+	}
+	var allowed map[string]bool
+	if policy == "curated" {
+		names, err := curatedTagNames(db, forumID)
+		if err != nil {
+			return nil, err
+		}
+		allowed = make(map[string]bool, len(names))
+		for _, name := range names {
+			allowed[name] = true
+		}
+	}
+
+	selected := make([]tagSelection, 0, len(manual)+len(hashtags))
+	seen := make(map[string]bool)
+	var rejected []string
+	// Tags kept from an earlier edit stay even if the forum stopped curating them.
+	for _, name := range manual {
+		if allowed != nil && !allowed[name] && checkManual {
+			rejected = append(rejected, name)
+			continue
+		}
+		seen[name] = true
+		selected = append(selected, tagSelection{Name: name, Source: "manual"})
+	}
+	if len(rejected) > 0 {
+		return nil, &tagRejectedError{Names: rejected}
+	}
+	for _, name := range hashtags {
+		if len(selected) == maxTagsPerPost {
+			break
+		}
+		if seen[name] || (allowed != nil && !allowed[name]) {
+			continue
+		}
+		seen[name] = true
+		selected = append(selected, tagSelection{Name: name, Source: "hashtag"})
+	}
+	return selected, nil
+}
+
+func ensureTags(tx *pg.Tx, names []string) (map[string]int, error) {
+	ids := make(map[string]int, len(names))
+	if len(names) == 0 {
+		return ids, nil
+	}
+	rows := make([]Models.Tags, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, Models.Tags{Name: name, CreatedAt: time.Now()})
+	}
+	if _, err := tx.Model(&rows).OnConflict("(name) DO NOTHING").Insert(); err != nil {
+		return nil, err
+	}
+	var tags []Models.Tags
+	if err := tx.Model(&tags).Where("name IN (?)", pg.In(names)).Select(); err != nil {
+		return nil, err
+	}
+	for _, t := range tags {
+		ids[t.Name] = t.ID
+	}
+	return ids, nil
+}
+
+func syncPostTags(tx *pg.Tx, postID int, selected []tagSelection) ([]string, error) {
+	names := make([]string, 0, len(selected))
+	for _, s := range selected {
+		names = append(names, s.Name)
+	}
+	ids, err := ensureTags(tx, names)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Model((*Models.PostTags)(nil)).Where("post_id = ?", postID).Delete(); err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 {
+		return names, nil
+	}
+	rows := make([]Models.PostTags, 0, len(selected))
+	for _, s := range selected {
+		rows = append(rows, Models.PostTags{PostID: postID, TagID: ids[s.Name], Source: s.Source, CreatedAt: time.Now()})
+	}
+	if _, err := tx.Model(&rows).Insert(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func loadPostTags(db *pg.DB, postIDs []int, source string) (map[int][]string, error) {
+	result := make(map[int][]string)
+	if len(postIDs) == 0 {
+		return result, nil
+	}
+	type PostTagRow struct {
+		PostID int
+		Name   string
+	}
+	var rows []PostTagRow
+	query := db.Model((*Models.PostTags)(nil)).
+		Column("post_tags.post_id").
+		ColumnExpr("t.name").
+		Join("JOIN tags AS t ON t.id = post_tags.tag_id").
+		Where("post_tags.post_id IN (?)", pg.In(postIDs))
+	if source != "" {
+		query.Where("post_tags.source = ?", source)
+	}
+	err := query.
+		OrderExpr("post_tags.source = 'manual' DESC, post_tags.created_at ASC, t.name ASC").
+		Select(&rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		result[r.PostID] = append(result[r.PostID], r.Name)
+	}
+	return result, nil
+}
+
+func attachTags(db *pg.DB, posts []Models.PostWithCounts) {
+	postIDs := make([]int, 0, len(posts))
+	for _, p := range posts {
+		postIDs = append(postIDs, p.ID)
+	}
+	tags, err := loadPostTags(db, postIDs, "")
+	if err != nil {
+		return
+	}
+	for i := range posts {
+		posts[i].Tags = tags[posts[i].ID]
+		if posts[i].Tags == nil {
+			posts[i].Tags = []string{}
+		}
+	}
+}
+
+func GetTagPosts(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	name, ok := normalizeTag(c.Param("tag_name"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag"})
+		return
+	}
+
+	tag := &Models.Tags{}
+	err = db.Model(tag).Where("name = ?", name).Select()
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tag"})
+		return
+	}
+
+	limit := messagePageLimit(c)
+	visible, args := visiblePostsCondition(db, userID)
+	var postIDs []int
+	query := db.Model((*Models.Posts)(nil)).
+		Column("posts.id").
+		Where("posts.id IN (SELECT post_id FROM post_tags WHERE tag_id = ?)", tag.ID).
+		Where(visible, args...)
+	if cursor := c.Query("cursor"); cursor != "" {
+		before, err := strconv.Atoi(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		query.Where("posts.id < ?", before)
+	}
+	if err := query.Order("posts.id DESC").Limit(limit + 1).Select(&postIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve posts"})
+		return
+	}
+
+	nextCursor := ""
+	if len(postIDs) > limit {
+		postIDs = postIDs[:limit]
+		nextCursor = strconv.Itoa(postIDs[limit-1])
+	}
+	posts, err := loadPostsWithCounts(db, postIDs, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve posts", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tag": tag, "posts": posts, "next_cursor": nextCursor})
+}
+
+func tagForumFilter(c *gin.Context, db *pg.DB, userID uuid.UUID) (*uuid.UUID, bool) {
+	rawForumID := c.Query("forum_id")
+	if rawForumID == "" {
+		return nil, true
+	}
+	forumID, err := uuid.Parse(rawForumID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return nil, false
+	}
+	allowed, err := canViewForum(db, userID, forumID)
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forum not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum access"})
+		return nil, false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This forum is private"})
+		return nil, false
+	}
+	return &forumID, true
+}
+
+func AutocompleteTags(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	forumID, ok := tagForumFilter(c, db, userID)
+	if !ok {
+		return
+	}
+
+	prefix := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(c.Query("q")), "#"))
+	tags := make([]tagWithCount, 0)
+	if prefix == "" {
+		c.JSON(http.StatusOK, gin.H{"tags": tags})
+		return
+	}
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+
+	if forumID != nil {
+		policy, err := forumTagPolicy(db, *forumID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve forum"})
+			return
+		}
+		if policy == "curated" {
+			_, err = db.Query(&tags, `
+				SELECT t.id, t.name,
+				       (SELECT COUNT(*) FROM post_tags pt JOIN posts p ON p.id = pt.post_id
+				        WHERE pt.tag_id = t.id AND p.forum_id = ?0 AND p.deleted_at IS NULL) AS post_count
+				FROM tags t
+				JOIN forum_tags ft ON ft.tag_id = t.id AND ft.forum_id = ?0
+				WHERE t.name LIKE ?1
+				ORDER BY post_count DESC, t.name ASC
+				LIMIT ?2
+			`, *forumID, pattern, tagSuggestLimit)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"tags": tags})
+			return
+		}
+	}
+
+	visible, args := visiblePostsCondition(db, userID)
+	query := db.Model((*Models.Tags)(nil)).
+		Column("tags.id", "tags.name").
+		ColumnExpr("COUNT(posts.id) AS post_count").
+		Join("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Join("JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL").
+		Where("tags.name LIKE ?", pattern).
+		Where(visible, args...)
+	if forumID != nil {
+		query.Where("posts.forum_id = ?", *forumID)
+	}
+	err = query.
+		Group("tags.id").
+		OrderExpr("post_count DESC, tags.name ASC").
+		Limit(tagSuggestLimit).
+		Select(&tags)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+func GetTrendingTags(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	forumID, ok := tagForumFilter(c, db, userID)
+	if !ok {
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days < 1 || days > 30 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 30"})
+		return
+	}
+
+	// Site-wide trends only count public forums so that the result is the same
+	// for everyone and can be cached.
+	cacheKey := fmt.Sprintf("trending_tags_%d", days)
+	if forumID != nil {
+		cacheKey = fmt.Sprintf("trending_tags_%d_%s", days, forumID.String())
+	}
+	if saved, found := ch.Get(cacheKey); found {
+		c.JSON(http.StatusOK, gin.H{"tags": saved, "days": days})
+		return
+	}
+
+	tags := make([]tagWithCount, 0)
+	query := db.Model((*Models.Tags)(nil)).
+		Column("tags.id", "tags.name").
+		ColumnExpr("COUNT(posts.id) AS post_count").
+		Join("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Join("JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL").
+		Where("posts.created_at >= ?", time.Now().AddDate(0, 0, -days))
+	if forumID != nil {
+		query.Where("posts.forum_id = ?", *forumID)
+	} else {
+		query.Where("posts.forum_id IN (SELECT fid FROM forums WHERE is_private = FALSE)")
+	}
+	err = query.
+		Group("tags.id").
+		OrderExpr("post_count DESC, MAX(posts.created_at) DESC").
+		Limit(trendingTagLimit).
+		Select(&tags)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+
+	ch.Set(cacheKey, tags, 5*time.Minute)
+	c.JSON(http.StatusOK, gin.H{"tags": tags, "days": days})
+}
+
+func GetForumTags(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	allowed, err := canViewForum(db, userID, forumID)
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forum not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum access"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This forum is private"})
+		return
+	}
+
+	policy, err := forumTagPolicy(db, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve forum"})
+		return
+	}
+	names, err := curatedTagNames(db, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tag_policy": policy, "tags": names})
+}
+
+func UpdateForumTags(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to manage this forum's tags",
+		})
+		return
+	}
+
+	var payload struct {
+		TagPolicy *string  `json:"tag_policy"`
+		Tags      []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+	if payload.TagPolicy != nil && *payload.TagPolicy != "free" && *payload.TagPolicy != "curated" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag_policy must be free or curated"})
+		return
+	}
+	var names []string
+	if payload.Tags != nil {
+		seen := make(map[string]bool)
+		names = make([]string, 0, len(payload.Tags))
+		for _, raw := range payload.Tags {
+			name, ok := normalizeTag(raw)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid tag %q", raw)})
+				return
+			}
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		if len(names) > maxCuratedTags {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A forum can curate at most %d tags", maxCuratedTags)})
+			return
+		}
+	}
+
+	beforePolicy, err := forumTagPolicy(db, forumID)
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forum not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve forum"})
+		return
+	}
+	beforeTags, err := curatedTagNames(db, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		if payload.TagPolicy != nil {
+			_, err := tx.Model((*Models.Forums)(nil)).
+				Set("tag_policy = ?", *payload.TagPolicy).
+				Set("updated_at = ?", time.Now()).
+				Where("fid = ?", forumID).
+				Update()
+			if err != nil {
+				return err
+			}
+		}
+		if names == nil {
+			return nil
+		}
+		ids, err := ensureTags(tx, names)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Model((*Models.ForumTags)(nil)).Where("forum_id = ?", forumID).Delete(); err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		rows := make([]Models.ForumTags, 0, len(names))
+		for _, name := range names {
+			rows = append(rows, Models.ForumTags{ForumID: forumID, TagID: ids[name], CreatedBy: userID, CreatedAt: time.Now()})
+		}
+		_, err = tx.Model(&rows).Insert()
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tags"})
+		return
+	}
+
+	policy := beforePolicy
+	if payload.TagPolicy != nil {
+		policy = *payload.TagPolicy
+	}
+	tags, err := curatedTagNames(db, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+
+	ch.Delete("forums_all")
+	ch.Delete(fmt.Sprintf("forum_%s", forumID.String()))
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "forum.tags",
+		TargetType: "forum",
+		TargetID:   forumID.String(),
+		Before:     gin.H{"tag_policy": beforePolicy, "tags": beforeTags},
+		After:      gin.H{"tag_policy": policy, "tags": tags},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Forum tags updated", "tag_policy": policy, "tags": tags})
+}
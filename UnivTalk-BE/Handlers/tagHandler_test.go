@@ -0,0 +1,74 @@
+package Handlers
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		want   string
+		wantOK bool
+	}{
+		{"lowercased", "Golang", "golang", true},
+		{"leading hash", "#Kalkulus", "kalkulus", true},
+		{"surrounding space", "  web_dev ", "web_dev", true},
+		{"trailing dashes trimmed", "go-lang--", "go-lang", true},
+		{"unicode letters", "Résumé", "résumé", true},
+		{"max length", strings.Repeat("a", maxTagLength), strings.Repeat("a", maxTagLength), true},
+		{"too long", strings.Repeat("a", maxTagLength+1), "", false},
+		{"digits only", "2024", "", false},
+		{"digits with letters", "uts2024", "uts2024", true},
+		{"empty", "", "", false},
+		{"hash only", "#", "", false},
+		{"leading dash", "-go", "", false},
+		{"symbols", "c++", "", false},
+		{"inner space", "machine learning", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := normalizeTag(tt.raw)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("normalizeTag(%q) = %q, %v, want %q, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseHashtags(t *testing.T) {
+	var many []string
+	for i := 0; i < maxTagsPerPost+2; i++ {
+		many = append(many, "#tag"+strconv.Itoa(i))
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"single tag", "Belajar #Golang hari ini", []string{"golang"}},
+		{"start of body", "#Kalkulus #Fisika", []string{"kalkulus", "fisika"}},
+		{"duplicates in different case", "#go dan #Go lagi #GO", []string{"go"}},
+		{"trailing dash", "selesai #uts-", []string{"uts"}},
+		{"unicode tag", "catatan #中文", []string{"中文"}},
+		{"digits only ignored", "issue #123", []string{}},
+		{"inside a word", "email a#b", []string{}},
+		{"URL fragment", "see https://example.com/#anchor", []string{}},
+		{"HTML entity", "it&#39;s", []string{}},
+		{"after parenthesis", "(#tag)", []string{}},
+		{"inline code", "run `#include` first #c", []string{"c"}},
+		{"code block", "```\n#define X\n```\n#cpp", []string{"cpp"}},
+		{"capped per post", strings.Join(many, " "), []string{"tag0", "tag1", "tag2", "tag3", "tag4", "tag5", "tag6", "tag7", "tag8", "tag9"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseHashtags(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseHashtags(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
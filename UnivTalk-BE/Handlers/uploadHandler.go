@@ -0,0 +1,482 @@
+package Handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	uploadChunkSize  = 8 << 20
+	uploadExpiry     = 24 * time.Hour
+	maxActiveUploads = 5
+	// Completed uploads wait longer for the post they are attached to.
+	completedUploadExpiry = 7 * 24 * time.Hour
+)
+
+var (
+	checksumPattern   = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	errUploadConsumed = fmt.Errorf("upload was already attached to another post")
+	errTooManyUploads = fmt.Errorf("too many uploads in progress")
+)
+
+func partialUploadDir() string {
+	if dir := os.Getenv("UPLOAD_PARTIAL_DIR"); dir != "" {
+		return dir
+	}
+	return "./partial-uploads"
+}
+
+func partialUploadPath(id uuid.UUID) string {
+	return filepath.Join(partialUploadDir(), id.String()+".part")
+}
+
+func removePartialUpload(id uuid.UUID) {
+	if err := os.Remove(partialUploadPath(id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Remove Partial Upload Failed (%s): %v", id, err)
+	}
+}
+
+func resumableSizeLimit(ext string) int64 {
+	for _, format := range mediaFormats {
+		for _, e := range format.Exts {
+			if e != ext {
+				continue
+			}
+			if format.MediaType == "video" {
+				return maxResumableVideoSize
+			}
+			return format.MaxSize
+		}
+	}
+	return 0
+}
+
+func uploadIDsFromRequest(c *gin.Context) []string {
+	ids := make([]string, 0)
+	for _, raw := range c.PostFormArray("upload_ids") {
+		for _, id := range strings.Split(raw, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+func loadCompletedUploads(db *pg.DB, userID uuid.UUID, rawIDs []string) ([]Models.MediaUploads, error) {
+	if len(rawIDs) == 0 {
+		return nil, nil
+	}
+	ids := make([]uuid.UUID, 0, len(rawIDs))
+	seen := make(map[uuid.UUID]bool, len(rawIDs))
+	for _, raw := range rawIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upload ID %q", raw)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("upload %s is listed more than once", id)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	var found []Models.MediaUploads
+	err := db.Model(&found).
+		Where("id IN (?)", pg.In(ids)).
+		Where("user_id = ?", userID).
+		Where("status = 'complete'").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]Models.MediaUploads, len(found))
+	for _, u := range found {
+		byID[u.ID] = u
+	}
+
+	uploads := make([]Models.MediaUploads, 0, len(ids))
+	for _, id := range ids {
+		u, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("upload %s does not exist or is not complete", id)
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, nil
+}
+
+func saveCompletedUpload(upload Models.MediaUploads, private bool) (*Models.PostAttachments, error) {
+	src, err := os.Open(partialUploadPath(upload.ID))
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return storeMediaFile(src, upload.SizeBytes, upload.Filename, private, true)
+}
+
+func consumeUploads(tx *pg.Tx, uploads []Models.MediaUploads) error {
+	if len(uploads) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(uploads))
+	for _, u := range uploads {
+		ids = append(ids, u.ID)
+	}
+	res, err := tx.Model((*Models.MediaUploads)(nil)).
+		Where("id IN (?)", pg.In(ids)).
+		Where("status = 'complete'").
+		Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() != len(ids) {
+		return errUploadConsumed
+	}
+	return nil
+}
+
+func removeConsumedUploads(uploads []Models.MediaUploads) {
+	for _, u := range uploads {
+		removePartialUpload(u.ID)
+	}
+}
+
+func getOwnedUpload(c *gin.Context, db *pg.DB) (*Models.MediaUploads, bool) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	uploadID, err := uuid.Parse(c.Param("upload_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload ID format"})
+		return nil, false
+	}
+
+	var upload Models.MediaUploads
+	err = db.Model(&upload).
+		Where("id = ?", uploadID).
+		Where("user_id = ?", userID).
+		Select()
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve upload"})
+		return nil, false
+	}
+	return &upload, true
+}
+
+func CreateUpload(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var payload struct {
+		Filename  string `json:"filename"`
+		SizeBytes int64  `json:"size_bytes"`
+		Checksum  string `json:"checksum"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+	payload.Filename = filepath.Base(strings.TrimSpace(payload.Filename))
+	payload.Checksum = strings.ToLower(strings.TrimSpace(payload.Checksum))
+
+	if _, err := mediaTypeForFile(payload.Filename); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media", "detail": err.Error()})
+		return
+	}
+	maxSize := resumableSizeLimit(strings.ToLower(filepath.Ext(payload.Filename)))
+	if payload.SizeBytes <= 0 || payload.SizeBytes > maxSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media", "detail": fmt.Sprintf("size_bytes must be between 1 and %d", maxSize)})
+		return
+	}
+	if !checksumPattern.MatchString(payload.Checksum) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "checksum must be the hex SHA-256 of the whole file"})
+		return
+	}
+
+	err = checkStorageQuota(db, userID, uuid.Nil, payload.SizeBytes, 0)
+	if exceeded, ok := err.(*quotaExceededError); ok {
+		c.JSON(http.StatusRequestEntityTooLarge, quotaExceededResponse(exceeded))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+		return
+	}
+
+	now := time.Now()
+	upload := Models.MediaUploads{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Filename:  payload.Filename,
+		SizeBytes: payload.SizeBytes,
+		Checksum:  payload.Checksum,
+		Status:    "uploading",
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(uploadExpiry),
+	}
+
+	path := partialUploadPath(upload.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	// The upload row reserves its full size against the user's quota, so
+	// the check and the insert share one locked transaction.
+	var active int
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		if err := checkStorageQuotaLocked(tx, userID, uuid.Nil, payload.SizeBytes, 0); err != nil {
+			return err
+		}
+		active, err = tx.Model((*Models.MediaUploads)(nil)).
+			Where("user_id = ?", userID).
+			Where("status = 'uploading'").
+			Where("expires_at > ?", now).
+			Count()
+		if err != nil {
+			return err
+		}
+		if active >= maxActiveUploads {
+			return errTooManyUploads
+		}
+		_, err = tx.Model(&upload).Insert()
+		return err
+	})
+	if err != nil {
+		removePartialUpload(upload.ID)
+	}
+	if exceeded, ok := err.(*quotaExceededError); ok {
+		c.JSON(http.StatusRequestEntityTooLarge, quotaExceededResponse(exceeded))
+		return
+	}
+	if err == errTooManyUploads {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("At most %d uploads can be in progress at once", maxActiveUploads)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	c.Header("Upload-Offset", "0")
+	c.JSON(http.StatusCreated, gin.H{"upload": upload, "chunk_size": uploadChunkSize})
+}
+
+func GetUpload(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	upload, ok := getOwnedUpload(c, db)
+	if !ok {
+		return
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.JSON(http.StatusOK, gin.H{"upload": upload, "chunk_size": uploadChunkSize})
+}
+
+// validateUploadChunk checks a chunk against the upload's current state. It
+// returns 0 when the chunk can be written at offset, or the HTTP status and
+// body to reject it with.
+func validateUploadChunk(upload *Models.MediaUploads, offset int64, chunk []byte, checksumHeader string) (int, gin.H) {
+	if upload.Status != "uploading" {
+		return http.StatusConflict, gin.H{"error": "Upload is not accepting data", "status": upload.Status}
+	}
+	if offset != upload.Offset {
+		return http.StatusConflict, gin.H{"error": "Offset mismatch", "offset": upload.Offset}
+	}
+	if len(chunk) == 0 {
+		return http.StatusBadRequest, gin.H{"error": "Chunk is empty"}
+	}
+	if offset+int64(len(chunk)) > upload.SizeBytes {
+		return http.StatusBadRequest, gin.H{"error": "Chunk exceeds the declared upload size"}
+	}
+	if checksumHeader != "" {
+		algorithm, encoded, _ := strings.Cut(checksumHeader, " ")
+		expected, err := base64.StdEncoding.DecodeString(encoded)
+		if !strings.EqualFold(algorithm, "sha256") || err != nil {
+			return http.StatusBadRequest, gin.H{"error": "Upload-Checksum must be \"sha256 <base64 digest>\""}
+		}
+		sum := sha256.Sum256(chunk)
+		if !bytes.Equal(sum[:], expected) {
+			return http.StatusBadRequest, gin.H{"error": "Chunk checksum mismatch", "offset": upload.Offset}
+		}
+	}
+	return 0, nil
+}
+
+func UploadChunk(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	upload, ok := getOwnedUpload(c, db)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset header is required"})
+		return
+	}
+	chunk, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, uploadChunkSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Chunks must be at most %d bytes", uploadChunkSize)})
+		return
+	}
+
+	var rejectStatus int
+	var rejectBody gin.H
+	var finishErr error
+	err = db.RunInTransaction(c.Request.Context(), func(tx *pg.Tx) error {
+		// The row lock is taken before the file is touched, so only one
+		// request at a time writes to the partial file, at the offset the
+		// row records.
+		if err := tx.Model(upload).WherePK().For("UPDATE").Select(); err != nil {
+			return err
+		}
+		if rejectStatus, rejectBody = validateUploadChunk(upload, offset, chunk, c.GetHeader("Upload-Checksum")); rejectStatus != 0 {
+			return nil
+		}
+
+		f, err := os.OpenFile(partialUploadPath(upload.ID), os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteAt(chunk, offset)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		upload.Offset = offset + int64(len(chunk))
+		upload.UpdatedAt = now
+		upload.ExpiresAt = now.Add(uploadExpiry)
+		if upload.Offset == upload.SizeBytes {
+			if finishErr = finishUpload(upload); finishErr != nil {
+				upload.Status = "failed"
+			} else {
+				upload.Status = "complete"
+				upload.ExpiresAt = now.Add(completedUploadExpiry)
+			}
+		}
+		_, err = tx.Model(upload).
+			Column("offset_bytes", "status", "updated_at", "expires_at").
+			WherePK().
+			Update()
+		return err
+	})
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Store Upload Chunk Failed (%s): %v", upload.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store chunk"})
+		return
+	}
+	if rejectStatus != 0 {
+		if rejectStatus == http.StatusConflict {
+			c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		}
+		c.JSON(rejectStatus, rejectBody)
+		return
+	}
+
+	if finishErr != nil {
+		removePartialUpload(upload.ID)
+		if rejected, ok := finishErr.(*mediaRejectedError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media", "detail": rejected.Error(), "upload": upload})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Upload failed", "detail": finishErr.Error(), "upload": upload})
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.JSON(http.StatusOK, gin.H{"upload": upload})
+}
+
+func finishUpload(upload *Models.MediaUploads) error {
+	f, err := os.Open(partialUploadPath(upload.ID))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != upload.Checksum {
+		return fmt.Errorf("file checksum does not match the declared checksum")
+	}
+
+	_, err = inspectMedia(f, upload.SizeBytes, strings.ToLower(filepath.Ext(upload.Filename)), true)
+	return err
+}
+
+func CancelUpload(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	upload, ok := getOwnedUpload(c, db)
+	if !ok {
+		return
+	}
+	if _, err := db.Model(upload).WherePK().Delete(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel upload"})
+		return
+	}
+	removePartialUpload(upload.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Upload cancelled"})
+}
+
+func StartUploadExpiryJob(db *pg.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var expired []Models.MediaUploads
+		_, err := db.Model(&expired).
+			Where("expires_at <= ?", time.Now()).
+			Returning("id").
+			Delete()
+		if err != nil {
+			log.Printf("Upload Expiry Job Failed: %v", err)
+			continue
+		}
+		for _, u := range expired {
+			removePartialUpload(u.ID)
+		}
+		if len(expired) > 0 {
+			log.Printf("Upload Expiry Job: removed %d expired uploads", len(expired))
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package Handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/google/uuid"
+)
+
+func chunkChecksum(chunk []byte) string {
+	sum := sha256.Sum256(chunk)
+	return "sha256 " + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestValidateUploadChunk(t *testing.T) {
+	chunk := []byte("lecture-part")
+	tests := []struct {
+		name     string
+		status   string
+		current  int64
+		size     int64
+		offset   int64
+		chunk    []byte
+		checksum string
+		want     int
+	}{
+		{"first chunk", "uploading", 0, 100, 0, chunk, "", 0},
+		{"next chunk with checksum", "uploading", 50, 100, 50, chunk, chunkChecksum(chunk), 0},
+		{"last chunk fills the upload", "uploading", 88, 100, 88, chunk, "", 0},
+		{"complete upload", "complete", 100, 100, 100, chunk, "", http.StatusConflict},
+		{"failed upload", "failed", 20, 100, 20, chunk, "", http.StatusConflict},
+		{"offset behind", "uploading", 50, 100, 38, chunk, "", http.StatusConflict},
+		{"offset ahead", "uploading", 50, 100, 62, chunk, "", http.StatusConflict},
+		{"empty chunk", "uploading", 0, 100, 0, nil, "", http.StatusBadRequest},
+		{"chunk past declared size", "uploading", 90, 100, 90, chunk, "", http.StatusBadRequest},
+		{"checksum mismatch", "uploading", 0, 100, 0, chunk, chunkChecksum([]byte("other")), http.StatusBadRequest},
+		{"unknown checksum algorithm", "uploading", 0, 100, 0, chunk, "md5 " + base64.StdEncoding.EncodeToString([]byte("x")), http.StatusBadRequest},
+		{"checksum not base64", "uploading", 0, 100, 0, chunk, "sha256 !!!", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upload := &Models.MediaUploads{Status: tt.status, Offset: tt.current, SizeBytes: tt.size}
+			got, body := validateUploadChunk(upload, tt.offset, tt.chunk, tt.checksum)
+			if got != tt.want {
+				t.Fatalf("status = %d (%v), want %d", got, body, tt.want)
+			}
+			if got == http.StatusConflict && tt.status == "uploading" && body["offset"] != tt.current {
+				t.Errorf("offset in response = %v, want %d", body["offset"], tt.current)
+			}
+		})
+	}
+}
+
+func TestFinishUploadChecksum(t *testing.T) {
+	t.Setenv("UPLOAD_PARTIAL_DIR", t.TempDir())
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	data := buf.Bytes()
+	sum := sha256.Sum256(data)
+
+	tests := []struct {
+		name     string
+		checksum string
+		wantErr  bool
+	}{
+		{"matching checksum", hex.EncodeToString(sum[:]), false},
+		{"different checksum", hex.EncodeToString(make([]byte, sha256.Size)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upload := &Models.MediaUploads{
+				ID:        uuid.New(),
+				Filename:  "diagram.png",
+				SizeBytes: int64(len(data)),
+				Checksum:  tt.checksum,
+			}
+			if err := os.WriteFile(partialUploadPath(upload.ID), data, 0600); err != nil {
+				t.Fatalf("write partial upload: %v", err)
+			}
+			err := finishUpload(upload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("finishUpload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package Handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/Ariffansyah/UnivTalk/Storage"
+	"github.com/disintegration/imaging"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	thumbnailSize      = 240
+	webpQuality        = 80
+	variantBatchSize   = 50
+	variantMaxAttempts = 3
+	variantJobTimeout  = 15 * time.Minute
+)
+
+var (
+	variantWidths = []int{320, 640, 1280}
+	variantQueue  = make(chan int, 256)
+)
+
+func initialVariantsStatus(format mediaFormat) string {
+	if format.MediaType == "image" {
+		return "pending"
+	}
+	return "none"
+}
+
+func queueAttachmentVariants(attachments []Models.PostAttachments) {
+	for _, a := range attachments {
+		if a.VariantsStatus != "pending" {
+			continue
+		}
+		select {
+		case variantQueue <- a.ID:
+		default:
+		}
+	}
+}
+
+func StartMediaVariantWorker(db *pg.DB, interval time.Duration) {
+	if _, err := webpEncoderPath(); err != nil {
+		log.Printf("Media Variant Job: WebP variants are disabled, install cwebp or set CWEBP_PATH (%v)", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case id := <-variantQueue:
+			processAttachmentVariants(db, id)
+		case <-ticker.C:
+			// Jobs interrupted by a crash or restart stay in 'processing';
+			// retry them a few times before giving up.
+			_, err := db.Model((*Models.PostAttachments)(nil)).
+				Set("variants_status = CASE WHEN variants_attempts < ? THEN 'pending' ELSE 'failed' END", variantMaxAttempts).
+				Where("variants_status = 'processing'").
+				Where("variants_started_at < ?", time.Now().Add(-variantJobTimeout)).
+				Update()
+			if err != nil {
+				log.Printf("Media Variant Job Failed: %v", err)
+			}
+
+			var ids []int
+			err = db.Model((*Models.PostAttachments)(nil)).
+				Column("id").
+				Where("variants_status = 'pending'").
+				Order("id ASC").
+				Limit(variantBatchSize).
+				Select(&ids)
+			if err != nil {
+				log.Printf("Media Variant Job Failed: %v", err)
+				continue
+			}
+			for _, id := range ids {
+				processAttachmentVariants(db, id)
+			}
+		}
+	}
+}
+
+func processAttachmentVariants(db *pg.DB, attachmentID int) {
+	var attachment Models.PostAttachments
+	res, err := db.Model(&attachment).
+		Set("variants_status = 'processing'").
+		Set("variants_attempts = variants_attempts + 1").
+		Set("variants_started_at = ?", time.Now()).
+		Where("id = ?", attachmentID).
+		Where("variants_status = 'pending'").
+		Returning("*").
+		Update()
+	if err != nil || res.RowsAffected() == 0 {
+		return
+	}
+
+	variants, err := generateVariants(&attachment)
+	status := "ready"
+	if err == nil && len(variants) > 0 {
+		_, err = db.Model(&variants).Insert()
+	}
+	if err != nil {
+		log.Printf("Media Variant Job Failed (attachment %d, attempt %d): %v", attachment.ID, attachment.VariantsAttempts, err)
+		removeVariantFiles(variants)
+		status = "failed"
+		// Storage or database errors may be temporary, so the job goes
+		// back to the queue; images that cannot be processed fail at once.
+		if _, rejected := err.(*mediaRejectedError); !rejected && attachment.VariantsAttempts < variantMaxAttempts {
+			status = "pending"
+		}
+	}
+
+	_, err = db.Model((*Models.PostAttachments)(nil)).
+		Set("variants_status = ?", status).
+		Where("id = ?", attachment.ID).
+		Update()
+	if err != nil {
+		log.Printf("Media Variant Job Failed (attachment %d): %v", attachment.ID, err)
+	}
+}
+
+func generateVariants(attachment *Models.PostAttachments) ([]Models.AttachmentVariants, error) {
+	ctx := context.Background()
+	src, err := mediaStorage.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(src)
+	src.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkImageDimensions(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	img, err := imaging.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, rejectMedia("image could not be decoded: %v", err)
+	}
+
+	format, ext := imaging.JPEG, ".jpg"
+	if attachment.MimeType == "image/png" || attachment.MimeType == "image/gif" {
+		format, ext = imaging.PNG, ".png"
+	}
+	base := strings.TrimSuffix(attachment.StorageKey, path.Ext(attachment.StorageKey))
+
+	variants := make([]Models.AttachmentVariants, 0, len(variantWidths)*2+2)
+	save := func(kind string, resized image.Image, suffix string) error {
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, resized, format, imaging.JPEGQuality(85)); err != nil {
+			return err
+		}
+		encoded := buf.Bytes()
+		if err := storeVariant(&variants, attachment.ID, kind, strings.TrimPrefix(ext, "."), base+suffix+ext, resized, encoded); err != nil {
+			return err
+		}
+
+		webpData, err := encodeWebP(resized)
+		if err != nil {
+			return err
+		}
+		if webpData != nil {
+			return storeVariant(&variants, attachment.ID, kind, "webp", base+suffix+".webp", resized, webpData)
+		}
+		return nil
+	}
+
+	if err := save("thumbnail", imaging.Fill(img, thumbnailSize, thumbnailSize, imaging.Center, imaging.Lanczos), "_thumb"); err != nil {
+		return variants, err
+	}
+	for _, width := range variantWidths {
+		if width >= img.Bounds().Dx() {
+			break
+		}
+		if err := save("resized", imaging.Resize(img, width, 0, imaging.Lanczos), fmt.Sprintf("_w%d", width)); err != nil {
+			return variants, err
+		}
+	}
+	return variants, nil
+}
+
+func storeVariant(variants *[]Models.AttachmentVariants, attachmentID int, kind string, format string, key string, img image.Image, data []byte) error {
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	err := mediaStorage.Put(context.Background(), key, bytes.NewReader(data), int64(len(data)), "image/"+format)
+	if err != nil {
+		return err
+	}
+	*variants = append(*variants, Models.AttachmentVariants{
+		AttachmentID: attachmentID,
+		Kind:         kind,
+		Format:       format,
+		URL:          publicURLForKey(key),
+		StorageKey:   key,
+		Width:        img.Bounds().Dx(),
+		Height:       img.Bounds().Dy(),
+		SizeBytes:    int64(len(data)),
+		CreatedAt:    time.Now(),
+	})
+	return nil
+}
+
+func webpEncoderPath() (string, error) {
+	if encoder := os.Getenv("CWEBP_PATH"); encoder != "" {
+		return exec.LookPath(encoder)
+	}
+	return exec.LookPath("cwebp")
+}
+
+// encodeWebP returns nil without an error when cwebp is not installed. A
+// CWEBP_PATH that does not point to an executable is an error.
+func encodeWebP(img image.Image) ([]byte, error) {
+	encoder, err := webpEncoderPath()
+	if err != nil {
+		if os.Getenv("CWEBP_PATH") != "" {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	dir, err := os.MkdirTemp("", "univtalk-webp-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	// The source is the decoded image saved losslessly, so the WebP copy is
+	// not encoded from an already lossy JPEG.
+	source := filepath.Join(dir, "source.png")
+	target := filepath.Join(dir, "target.webp")
+	if err := imaging.Save(img, source); err != nil {
+		return nil, err
+	}
+
+	output, err := exec.Command(encoder, "-quiet", "-metadata", "none", "-q", strconv.Itoa(webpQuality), source, "-o", target).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("cwebp: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return os.ReadFile(target)
+}
+
+func removeVariantFiles(variants []Models.AttachmentVariants) {
+	keys := make([]string, 0, len(variants))
+	for _, v := range variants {
+		keys = append(keys, v.StorageKey)
+	}
+	deleteStoredMedia(keys)
+}
+
+func attachVariants(db *pg.DB, attachments map[int][]Models.PostAttachments) error {
+	ids := make([]int, 0)
+	for _, list := range attachments {
+		for _, a := range list {
+			ids = append(ids, a.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var variants []Models.AttachmentVariants
+	err := db.Model(&variants).
+		Where("attachment_id IN (?)", pg.In(ids)).
+		Order("attachment_id ASC", "width ASC", "format ASC").
+		Select()
+	if err != nil {
+		return err
+	}
+	byAttachment := make(map[int][]Models.AttachmentVariants, len(ids))
+	for _, v := range variants {
+		byAttachment[v.AttachmentID] = append(byAttachment[v.AttachmentID], v)
+	}
+
+	for postID, list := range attachments {
+		for i := range list {
+			list[i].Variants = byAttachment[list[i].ID]
+			if list[i].Variants == nil {
+				list[i].Variants = []Models.AttachmentVariants{}
+			}
+		}
+		attachments[postID] = list
+	}
+	return nil
+}
+
+func pickVariant(attachment *Models.PostAttachments, width int, preferWebP bool) string {
+	formats := []bool{false}
+	if preferWebP {
+		formats = []bool{true, false}
+	}
+	for _, webpOnly := range formats {
+		best := -1
+		for i, v := range attachment.Variants {
+			if v.Kind != "resized" || (v.Format == "webp") != webpOnly || v.Width < width {
+				continue
+			}
+			if best < 0 || v.Width < attachment.Variants[best].Width {
+				best = i
+			}
+		}
+		if best >= 0 {
+			return attachment.Variants[best].URL
+		}
+	}
+	return attachment.URL
+}
+
+func ServeAttachmentVariant(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	attachmentID, err := strconv.Atoi(c.Param("attachment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Attachment ID format"})
+		return
+	}
+
+	var attachment Models.PostAttachments
+	if err := db.Model(&attachment).Where("id = ?", attachmentID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	var post Models.Posts
+	if err := db.Model(&post).Column("forum_id").Where("id = ?", attachment.PostID).Select(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	privateForum, err := isPrivateForum(db, post.ForumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify forum access"})
+		return
+	}
+	if privateForum {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Private media is only available through signed links"})
+		return
+	}
+	loaded := map[int][]Models.PostAttachments{attachment.PostID: {attachment}}
+	if err := attachVariants(db, loaded); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve variants"})
+		return
+	}
+	attachment = loaded[attachment.PostID][0]
+	if Storage.IsPrivateKey(attachment.StorageKey) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Private media is only available through signed links"})
+		return
+	}
+	resolveAttachmentURLs(loaded[attachment.PostID], false)
+	attachment = loaded[attachment.PostID][0]
+
+	preferWebP := strings.Contains(c.GetHeader("Accept"), "image/webp")
+	if format := c.Query("format"); format != "" {
+		preferWebP = format == "webp"
+	}
+
+	target := attachment.URL
+	if c.Query("size") == "thumbnail" {
+		for _, v := range attachment.Variants {
+			if v.Kind != "thumbnail" {
+				continue
+			}
+			if target == attachment.URL || (v.Format == "webp") == preferWebP {
+				target = v.URL
+			}
+		}
+	} else if widthStr := c.Query("w"); widthStr != "" {
+		width, err := strconv.Atoi(widthStr)
+		if err != nil || width <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid width"})
+			return
+		}
+		target = pickVariant(&attachment, width, preferWebP)
+	}
+
+	c.Header("Vary", "Accept")
+	c.Redirect(http.StatusFound, target)
+}
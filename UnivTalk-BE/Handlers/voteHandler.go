@@ -12,6 +12,43 @@ import (
 	"github.com/patrickmn/go-cache"
 )
 
+func getVoteTargetPost(db *pg.DB, postID *int, commentID *int) (*Models.Posts, error) {
+	var post Models.Posts
+	targetPostID := 0
+	if postID != nil {
+		targetPostID = *postID
+	} else {
+		var cmt Models.Comments
+		if err := db.Model(&cmt).Column("post_id").Where("id = ?", *commentID).Select(); err != nil {
+			return nil, err
+		}
+		targetPostID = cmt.PostID
+	}
+	err := db.Model(&post).Column("id", "forum_id", "is_locked").Where("id = ?", targetPostID).Select()
+	if err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+func rejectBannedVoter(c *gin.Context, db *pg.DB, userID uuid.UUID, postID *int, commentID *int) bool {
+	targetPost, err := getVoteTargetPost(db, postID, commentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
+		return true
+	}
+	ban, err := getActiveForumBan(db, targetPost.ForumID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return true
+	}
+	if ban != nil {
+		c.JSON(http.StatusForbidden, forumBanResponse(ban))
+		return true
+	}
+	return false
+}
+
 func processVote(c *gin.Context, db *pg.DB, ch *cache.Cache, postID *int, commentID *int, value int) {
 	userIDInterface, exists := c.Get("user_id")
 	if !exists {
@@ -23,6 +60,27 @@ func processVote(c *gin.Context, db *pg.DB, ch *cache.Cache, postID *int, commen
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "User ID format error"})
 		return
 	}
+	targetPost, err := getVoteTargetPost(db, postID, commentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
+		return
+	}
+	if rejectLockedPost(c, targetPost, "Voting is not allowed on this post") {
+		return
+	}
+	ban, err := getActiveForumBan(db, targetPost.ForumID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if ban != nil {
+		c.JSON(http.StatusForbidden, forumBanResponse(ban))
+		return
+	}
+	if rejectPrivateForum(c, db, userID, targetPost.ForumID) {
+		return
+	}
+
 	var vote Models.Votes
 	var existsQuery *pg.Query
 	if postID != nil {
@@ -30,7 +88,7 @@ func processVote(c *gin.Context, db *pg.DB, ch *cache.Cache, postID *int, commen
 	} else {
 		existsQuery = db.Model(&vote).Where("user_id = ? AND comment_id = ?", userID, *commentID)
 	}
-	err := existsQuery.Select()
+	err = existsQuery.Select()
 	if err != nil {
 		if err != pg.ErrNoRows {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
@@ -74,6 +132,10 @@ func processVote(c *gin.Context, db *pg.DB, ch *cache.Cache, postID *int, commen
 			ch.Delete(fmt.Sprintf("comments_post_%d", cmt.PostID))
 		}
 	}
+	publishVoteCounts(db, targetPost.ForumID, targetPost.ID, commentID)
+	if value == 1 {
+		notifyVoteMilestoneReached(db, targetPost.ForumID, postID, commentID)
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Vote processed", "value": value})
 }
 
@@ -90,6 +152,9 @@ func DownVotePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 func RemoveVotePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 	postID, _ := strconv.Atoi(c.Param("post_id"))
 	userID := c.MustGet("user_id").(uuid.UUID)
+	if rejectBannedVoter(c, db, userID, &postID, nil) {
+		return
+	}
 	_, err := db.Model(&Models.Votes{}).Where("user_id = ? AND post_id = ?", userID, postID).Delete()
 	if err == nil {
 		ch.Delete("global_posts")
@@ -97,6 +162,7 @@ func RemoveVotePost(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 		db.Model(&p).Where("id = ?", postID).Select()
 		ch.Delete(fmt.Sprintf("forum_posts_%s", p.ForumID))
 		ch.Delete(fmt.Sprintf("post_%d", postID))
+		publishVoteCounts(db, p.ForumID, postID, nil)
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Vote removed"})
 }
@@ -114,11 +180,17 @@ func DownVoteComment(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 func RemoveVoteComment(c *gin.Context, db *pg.DB, ch *cache.Cache) {
 	id, _ := strconv.Atoi(c.Param("comment_id"))
 	userID := c.MustGet("user_id").(uuid.UUID)
+	if rejectBannedVoter(c, db, userID, nil, &id) {
+		return
+	}
 	_, _ = db.Model(&Models.Votes{}).Where("user_id = ? AND comment_id = ?", userID, id).Delete()
 
 	var cmt Models.Comments
 	if err := db.Model(&cmt).Where("id = ?", id).Select(); err == nil {
 		ch.Delete(fmt.Sprintf("comments_post_%d", cmt.PostID))
+		if forumID, err := getForumIDByPostID(db, cmt.PostID); err == nil {
+			publishVoteCounts(db, forumID, cmt.PostID, &id)
+		}
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Vote removed"})
 }
@@ -167,6 +239,19 @@ func GetPostVoters(c *gin.Context, db *pg.DB) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Post ID"})
 		return
 	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	forumID, err := getForumIDByPostID(db, postID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if rejectPrivateForum(c, db, userID, forumID) {
+		return
+	}
 	type Row struct {
 		UserID   uuid.UUID `json:"user_id"`
 		Username string    `json:"username"`
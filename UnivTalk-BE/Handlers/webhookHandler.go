@@ -0,0 +1,667 @@
+package Handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	maxForumWebhooks       = 5
+	maxWebhookURLLength    = 2048
+	maxWebhookAttempts     = 6
+	maxWebhookResponseBody = 1024
+	webhookBaseBackoff     = 30 * time.Second
+	webhookMaxBackoff      = 1 * time.Hour
+	webhookTimeout         = 10 * time.Second
+	webhookLease           = 5 * time.Minute
+	webhookBatchSize       = 50
+
+	webhookPostCreated    = "post.created"
+	webhookCommentCreated = "comment.created"
+	webhookMemberJoined   = "member.joined"
+	webhookMemberLeft     = "member.left"
+	webhookPing           = "ping"
+)
+
+var (
+	webhookEvents = []string{webhookPostCreated, webhookCommentCreated, webhookMemberJoined, webhookMemberLeft}
+	webhookQueue  = make(chan int, 256)
+
+	errWebhookPrivateAddress = errors.New("webhook URL resolves to a private address")
+
+	webhookClient = &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: webhookDialControl}).DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+func webhookDialControl(network string, address string, conn syscall.RawConn) error {
+	if os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errWebhookPrivateAddress
+	}
+	return nil
+}
+
+func validateWebhookURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) > maxWebhookURLLength {
+		return "", fmt.Errorf("url must be at most %d characters", maxWebhookURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("url must be an absolute http or https URL")
+	}
+	return u.String(), nil
+}
+
+func parseWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return append([]string{}, webhookEvents...), nil
+	}
+	parsed := make([]string, 0, len(events))
+	seen := make(map[string]bool, len(events))
+	for _, event := range events {
+		valid := false
+		for _, e := range webhookEvents {
+			valid = valid || e == event
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown event %q, expected one of %s", event, strings.Join(webhookEvents, ", "))
+		}
+		if !seen[event] {
+			seen[event] = true
+			parsed = append(parsed, event)
+		}
+	}
+	return parsed, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func signWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBackoff(attempt int) time.Duration {
+	backoff := webhookBaseBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > webhookMaxBackoff {
+		backoff = webhookMaxBackoff
+	}
+	return backoff + time.Duration(mathrand.Int63n(int64(backoff/10)+1))
+}
+
+func newWebhookDelivery(webhookID int, forumID uuid.UUID, event string, data interface{}) *Models.WebhookDeliveries {
+	now := time.Now()
+	return &Models.WebhookDeliveries{
+		WebhookID: webhookID,
+		Event:     event,
+		Payload: gin.H{
+			"event":       event,
+			"forum_id":    forumID,
+			"occurred_at": now,
+			"data":        data,
+		},
+		Status:        "pending",
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+	}
+}
+
+func queueWebhookDelivery(deliveryID int) {
+	select {
+	case webhookQueue <- deliveryID:
+	default:
+	}
+}
+
+func enqueueWebhookEvent(db *pg.DB, forumID uuid.UUID, event string, data interface{}) {
+	var hooks []Models.ForumWebhooks
+	err := db.Model(&hooks).
+		Where("forum_id = ?", forumID).
+		Where("active = TRUE").
+		Where("? = ANY(events)", event).
+		Select()
+	if err != nil {
+		log.Printf("Enqueue Webhook Failed (%s for forum %s): %v", event, forumID, err)
+		return
+	}
+	for _, hook := range hooks {
+		delivery := newWebhookDelivery(hook.ID, forumID, event, data)
+		if _, err := db.Model(delivery).Insert(); err != nil {
+			log.Printf("Enqueue Webhook Failed (%s for webhook %d): %v", event, hook.ID, err)
+			continue
+		}
+		queueWebhookDelivery(delivery.ID)
+	}
+}
+
+func enqueueMembershipWebhook(db *pg.DB, forumID uuid.UUID, userID uuid.UUID, event string) {
+	var user Models.Users
+	if err := db.Model(&user).Column("username").Where("uid = ?", userID).Select(); err != nil {
+		log.Printf("Enqueue Webhook Failed (%s for forum %s): %v", event, forumID, err)
+		return
+	}
+	enqueueWebhookEvent(db, forumID, event, gin.H{"user_id": userID, "username": user.Username})
+}
+
+func sendWebhook(hook *Models.ForumWebhooks, delivery *Models.WebhookDeliveries) bool {
+	delivery.ResponseStatus, delivery.ResponseBody, delivery.Error = nil, "", ""
+	body, err := json.Marshal(delivery.Payload)
+	if err != nil {
+		delivery.Error = err.Error()
+		return false
+	}
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return false
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "UnivTalk-Webhooks/1.0")
+	req.Header.Set("X-UnivTalk-Event", delivery.Event)
+	req.Header.Set("X-UnivTalk-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set("X-UnivTalk-Timestamp", timestamp)
+	req.Header.Set("X-UnivTalk-Signature", "sha256="+signWebhookPayload(hook.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := webhookClient.Do(req)
+	delivery.DurationMs = int(time.Since(start).Milliseconds())
+	if err != nil {
+		delivery.Error = err.Error()
+		return false
+	}
+	defer resp.Body.Close()
+
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseBody))
+	delivery.ResponseBody = strings.ReplaceAll(strings.ToValidUTF8(string(excerpt), ""), "\x00", "")
+	delivery.ResponseStatus = &resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		delivery.Error = "unexpected response status " + resp.Status
+		return false
+	}
+	return true
+}
+
+func deliverWebhook(db *pg.DB, deliveryID int) {
+	var delivery Models.WebhookDeliveries
+	now := time.Now()
+	res, err := db.Model(&delivery).
+		Set("attempts = attempts + 1").
+		Set("next_attempt_at = ?", now.Add(webhookLease)).
+		Where("id = ?", deliveryID).
+		Where("status = 'pending'").
+		Where("next_attempt_at <= ?", now).
+		Returning("*").
+		Update()
+	if err == pg.ErrNoRows || (err == nil && res.RowsAffected() == 0) {
+		return
+	}
+	if err != nil {
+		log.Printf("Webhook Delivery Failed (delivery %d): %v", deliveryID, err)
+		return
+	}
+
+	var hook Models.ForumWebhooks
+	err = db.Model(&hook).Where("id = ?", delivery.WebhookID).Select()
+	if err != nil {
+		log.Printf("Webhook Delivery Failed (delivery %d): %v", delivery.ID, err)
+		return
+	}
+
+	delivered := false
+	if !hook.Active && delivery.Event != webhookPing {
+		delivery.Error = "webhook is disabled"
+	} else {
+		delivered = sendWebhook(&hook, &delivery)
+	}
+
+	finished := time.Now()
+	switch {
+	case delivered:
+		delivery.Status, delivery.NextAttemptAt, delivery.DeliveredAt = "succeeded", nil, &finished
+	case !hook.Active || delivery.Event == webhookPing || delivery.Attempts >= maxWebhookAttempts:
+		delivery.Status, delivery.NextAttemptAt = "failed", nil
+	default:
+		next := finished.Add(webhookBackoff(delivery.Attempts))
+		delivery.Status, delivery.NextAttemptAt = "pending", &next
+	}
+	_, err = db.Model(&delivery).
+		Column("status", "next_attempt_at", "response_status", "response_body", "error", "duration_ms", "delivered_at").
+		WherePK().
+		Update()
+	if err != nil {
+		log.Printf("Webhook Delivery Failed (delivery %d): %v", delivery.ID, err)
+	}
+}
+
+func StartWebhookWorker(db *pg.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case id := <-webhookQueue:
+			deliverWebhook(db, id)
+		case <-ticker.C:
+			var ids []int
+			err := db.Model((*Models.WebhookDeliveries)(nil)).
+				Column("id").
+				Where("status = 'pending'").
+				Where("next_attempt_at <= ?", time.Now()).
+				Order("next_attempt_at ASC").
+				Limit(webhookBatchSize).
+				Select(&ids)
+			if err != nil {
+				log.Printf("Webhook Job Failed: %v", err)
+				continue
+			}
+			for _, id := range ids {
+				deliverWebhook(db, id)
+			}
+		}
+	}
+}
+
+func requireWebhookAdmin(c *gin.Context, db *pg.DB) (uuid.UUID, uuid.UUID, bool) {
+	forumID, err := uuid.Parse(c.Param("forum_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Forum ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return uuid.Nil, uuid.Nil, false
+	}
+	hasAccess, err := canModerateForum(db, userID, forumID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user privileges"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Forbidden",
+			"detail": "You do not have permission to manage this forum's webhooks",
+		})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return forumID, userID, true
+}
+
+func getForumWebhook(c *gin.Context, db *pg.DB, forumID uuid.UUID) (*Models.ForumWebhooks, bool) {
+	webhookID, err := strconv.Atoi(c.Param("webhook_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Webhook ID format"})
+		return nil, false
+	}
+	var hook Models.ForumWebhooks
+	err = db.Model(&hook).
+		Where("id = ?", webhookID).
+		Where("forum_id = ?", forumID).
+		Select()
+	if err == pg.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook"})
+		return nil, false
+	}
+	return &hook, true
+}
+
+func GetForumWebhooks(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, _, ok := requireWebhookAdmin(c, db)
+	if !ok {
+		return
+	}
+
+	hooks := make([]Models.ForumWebhooks, 0)
+	err := db.Model(&hooks).
+		Where("forum_id = ?", forumID).
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhooks", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": hooks, "events": webhookEvents})
+}
+
+func CreateForumWebhook(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, userID, ok := requireWebhookAdmin(c, db)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		URL    string   `json:"url" binding:"required"`
+		Events []string `json:"events"`
+		Active *bool    `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+	hookURL, err := validateWebhookURL(payload.URL)
+	if err == nil {
+		payload.Events, err = parseWebhookEvents(payload.Events)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook", "detail": err.Error()})
+		return
+	}
+
+	count, err := db.Model((*Models.ForumWebhooks)(nil)).Where("forum_id = ?", forumID).Count()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhooks"})
+		return
+	}
+	if count >= maxForumWebhooks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A forum can have at most %d webhooks", maxForumWebhooks)})
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook secret"})
+		return
+	}
+	now := time.Now()
+	hook := &Models.ForumWebhooks{
+		ForumID:   forumID,
+		URL:       hookURL,
+		Secret:    secret,
+		Events:    payload.Events,
+		Active:    payload.Active == nil || *payload.Active,
+		CreatedBy: userID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := db.Model(hook).Insert(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook", "detail": err.Error()})
+		return
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "webhook.create",
+		TargetType: "webhook",
+		TargetID:   strconv.Itoa(hook.ID),
+		After:      hook,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Webhook created", "webhook": hook, "secret": secret})
+}
+
+func UpdateForumWebhook(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, userID, ok := requireWebhookAdmin(c, db)
+	if !ok {
+		return
+	}
+	hook, ok := getForumWebhook(c, db, forumID)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		URL    *string   `json:"url"`
+		Events *[]string `json:"events"`
+		Active *bool     `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "detail": err.Error()})
+		return
+	}
+
+	before := *hook
+	var err error
+	if payload.URL != nil {
+		hook.URL, err = validateWebhookURL(*payload.URL)
+	}
+	if err == nil && payload.Events != nil {
+		hook.Events, err = parseWebhookEvents(*payload.Events)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook", "detail": err.Error()})
+		return
+	}
+	if payload.Active != nil {
+		hook.Active = *payload.Active
+	}
+	hook.UpdatedAt = time.Now()
+
+	_, err = db.Model(hook).Column("url", "events", "active", "updated_at").WherePK().Update()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook", "detail": err.Error()})
+		return
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "webhook.update",
+		TargetType: "webhook",
+		TargetID:   strconv.Itoa(hook.ID),
+		Before:     before,
+		After:      hook,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook updated", "webhook": hook})
+}
+
+func DeleteForumWebhook(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, userID, ok := requireWebhookAdmin(c, db)
+	if !ok {
+		return
+	}
+	hook, ok := getForumWebhook(c, db, forumID)
+	if !ok {
+		return
+	}
+
+	if _, err := db.Model(hook).WherePK().Delete(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook", "detail": err.Error()})
+		return
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "webhook.delete",
+		TargetType: "webhook",
+		TargetID:   strconv.Itoa(hook.ID),
+		Before:     hook,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+func RotateForumWebhookSecret(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, userID, ok := requireWebhookAdmin(c, db)
+	if !ok {
+		return
+	}
+	hook, ok := getForumWebhook(c, db, forumID)
+	if !ok {
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook secret"})
+		return
+	}
+	hook.Secret, hook.UpdatedAt = secret, time.Now()
+	if _, err := db.Model(hook).Column("secret", "updated_at").WherePK().Update(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate webhook secret"})
+		return
+	}
+
+	recordAuditLog(db, &Models.AuditLogs{
+		ActorID:    userID,
+		ForumID:    &forumID,
+		Action:     "webhook.rotate_secret",
+		TargetType: "webhook",
+		TargetID:   strconv.Itoa(hook.ID),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook secret rotated", "webhook": hook, "secret": secret})
+}
+
+func PingForumWebhook(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, userID, ok := requireWebhookAdmin(c, db)
+	if !ok {
+		return
+	}
+	hook, ok := getForumWebhook(c, db, forumID)
+	if !ok {
+		return
+	}
+
+	delivery := newWebhookDelivery(hook.ID, forumID, webhookPing, gin.H{"webhook_id": hook.ID, "sent_by": userID})
+	if _, err := db.Model(delivery).Insert(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send ping", "detail": err.Error()})
+		return
+	}
+	deliverWebhook(db, delivery.ID)
+	if err := db.Model(delivery).WherePK().Select(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve delivery"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delivery": delivery})
+}
+
+func GetWebhookDeliveries(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, _, ok := requireWebhookAdmin(c, db)
+	if !ok {
+		return
+	}
+	hook, ok := getForumWebhook(c, db, forumID)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	deliveries := make([]Models.WebhookDeliveries, 0)
+	query := db.Model(&deliveries).Where("webhook_id = ?", hook.ID)
+	if status := c.Query("status"); status != "" {
+		query.Where("status = ?", status)
+	}
+	if event := c.Query("event"); event != "" {
+		query.Where("event = ?", event)
+	}
+	err = query.Order("created_at DESC", "id DESC").Limit(limit).Offset(offset).Select()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deliveries", "detail": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+func RedeliverWebhook(c *gin.Context, db *pg.DB, ch *cache.Cache) {
+	forumID, _, ok := requireWebhookAdmin(c, db)
+	if !ok {
+		return
+	}
+	hook, ok := getForumWebhook(c, db, forumID)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.Atoi(c.Param("delivery_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Delivery ID format"})
+		return
+	}
+
+	var original Models.WebhookDeliveries
+	err = db.Model(&original).
+		Where("id = ?", deliveryID).
+		Where("webhook_id = ?", hook.ID).
+		Select()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	if original.Status == "pending" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Delivery is still pending"})
+		return
+	}
+
+	now := time.Now()
+	delivery := &Models.WebhookDeliveries{
+		WebhookID:     hook.ID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        "pending",
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+	}
+	if _, err := db.Model(delivery).Insert(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue delivery", "detail": err.Error()})
+		return
+	}
+	queueWebhookDelivery(delivery.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Delivery queued", "delivery": delivery})
+}
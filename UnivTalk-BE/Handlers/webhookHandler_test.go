@@ -0,0 +1,101 @@
+package Handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Ariffansyah/UnivTalk/Models"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{"ping payload", "whsec_test", "1700000000", `{"event":"ping"}`, "aa8efe37b751e71157c508c5ac4acb1e9fe5225db98355dfc00f4b680afbc447"},
+		{"empty body", "whsec_test", "1700000000", "", "5967f3c560522fa40cf2876ebc3c3a08551dd6959aaade3b413460591895bdcc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signWebhookPayload(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("signWebhookPayload() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	base := signWebhookPayload("whsec_test", "1700000000", []byte(`{"event":"ping"}`))
+	changed := map[string]string{
+		"secret":    signWebhookPayload("whsec_other", "1700000000", []byte(`{"event":"ping"}`)),
+		"timestamp": signWebhookPayload("whsec_test", "1700000001", []byte(`{"event":"ping"}`)),
+		"body":      signWebhookPayload("whsec_test", "1700000000", []byte(`{"event":"pong"}`)),
+		// The separator keeps the timestamp from bleeding into the body.
+		"boundary": signWebhookPayload("whsec_test", "170000000", []byte(`0.{"event":"ping"}`)),
+	}
+	for field, sig := range changed {
+		if sig == base {
+			t.Errorf("changing the %s did not change the signature", field)
+		}
+	}
+}
+
+func TestSendWebhookSignsRequest(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE", "true")
+
+	var got *http.Request
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	hook := &Models.ForumWebhooks{URL: server.URL, Secret: "whsec_test"}
+	delivery := &Models.WebhookDeliveries{ID: 42, Event: webhookPing, Payload: map[string]string{"event": "ping"}}
+	if !sendWebhook(hook, delivery) {
+		t.Fatalf("sendWebhook() failed: %s", delivery.Error)
+	}
+
+	if got.Header.Get("X-UnivTalk-Event") != webhookPing {
+		t.Errorf("X-UnivTalk-Event = %q, want %q", got.Header.Get("X-UnivTalk-Event"), webhookPing)
+	}
+	if got.Header.Get("X-UnivTalk-Delivery") != "42" {
+		t.Errorf("X-UnivTalk-Delivery = %q, want 42", got.Header.Get("X-UnivTalk-Delivery"))
+	}
+	timestamp := got.Header.Get("X-UnivTalk-Timestamp")
+	want := "sha256=" + signWebhookPayload(hook.Secret, timestamp, gotBody)
+	if sig := got.Header.Get("X-UnivTalk-Signature"); sig != want {
+		t.Errorf("X-UnivTalk-Signature = %q, want %q", sig, want)
+	}
+	if delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusNoContent {
+		t.Errorf("ResponseStatus = %v, want %d", delivery.ResponseStatus, http.StatusNoContent)
+	}
+}
+
+func TestSendWebhookRejectsPrivateAddress(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE", "")
+
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	hook := &Models.ForumWebhooks{URL: server.URL, Secret: "whsec_test"}
+	delivery := &Models.WebhookDeliveries{Event: webhookPing, Payload: map[string]string{}}
+	if sendWebhook(hook, delivery) {
+		t.Fatal("sendWebhook() delivered to a loopback address")
+	}
+	if called {
+		t.Error("loopback server received the request")
+	}
+	if !strings.Contains(delivery.Error, errWebhookPrivateAddress.Error()) {
+		t.Errorf("Error = %q, want %q", delivery.Error, errWebhookPrivateAddress)
+	}
+}
@@ -0,0 +1,44 @@
+package Mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrNotConfigured = errors.New("SMTP_ADDR is not set")
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Headers map[string]string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+func NewFromEnv() (Mailer, error) {
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		return nil, ErrNotConfigured
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required when SMTP_ADDR is set")
+	}
+	return &SMTP{
+		Addr:     addr,
+		From:     from,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}, nil
+}
+
+func validHeaderValue(v string) bool {
+	return !strings.ContainsAny(v, "\r\n")
+}
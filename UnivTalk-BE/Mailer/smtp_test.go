@@ -0,0 +1,181 @@
+package Mailer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sinkMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// startSMTPSink runs a minimal SMTP server on a local port that accepts every
+// message and hands it to the returned channel.
+func startSMTPSink(t *testing.T) (string, <-chan sinkMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan sinkMessage, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTPSink(conn, messages)
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func serveSMTPSink(conn net.Conn, messages chan<- sinkMessage) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost sink")
+	var msg sinkMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg.From = strings.Trim(strings.TrimSpace(line)[len("MAIL FROM:"):], "<>")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.To = append(msg.To, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = data.String()
+			messages <- msg
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPSendDeliversMultipartMessage(t *testing.T) {
+	addr, messages := startSMTPSink(t)
+	sender := &SMTP{Addr: addr, From: "UnivTalk <noreply@univtalk.test>"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := sender.Send(ctx, Message{
+		To:      "budi@kampus.test",
+		Subject: "Ringkasan mingguan UnivTalk kamu",
+		Text:    "Halo Budi,\nada 3 post baru.",
+		HTML:    "<p>Halo Budi,</p><p>ada 3 post baru.</p>",
+		Headers: map[string]string{"List-Unsubscribe": "<https://api.univtalk.test/unsubscribe?token=abc>"},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var got sinkMessage
+	select {
+	case got = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sink received no message")
+	}
+	if got.From != "noreply@univtalk.test" {
+		t.Errorf("MAIL FROM = %q", got.From)
+	}
+	if len(got.To) != 1 || got.To[0] != "budi@kampus.test" {
+		t.Errorf("RCPT TO = %q", got.To)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(got.Data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "Ringkasan mingguan UnivTalk kamu" {
+		t.Errorf("Subject = %q (%v)", subject, err)
+	}
+	if h := parsed.Header.Get("List-Unsubscribe"); h != "<https://api.univtalk.test/unsubscribe?token=abc>" {
+		t.Errorf("List-Unsubscribe = %q", h)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v)", mediaType, err)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	bodies := make(map[string]string)
+	for {
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatalf("decode part: %v", err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[contentType] = string(body)
+	}
+	if bodies["text/plain"] != "Halo Budi,\r\nada 3 post baru." {
+		t.Errorf("text part = %q", bodies["text/plain"])
+	}
+	if bodies["text/html"] != "<p>Halo Budi,</p><p>ada 3 post baru.</p>" {
+		t.Errorf("html part = %q", bodies["text/html"])
+	}
+}
+
+func TestSMTPSendRejectsHeaderInjection(t *testing.T) {
+	addr, messages := startSMTPSink(t)
+	sender := &SMTP{Addr: addr, From: "noreply@univtalk.test"}
+
+	err := sender.Send(context.Background(), Message{
+		To:      "budi@kampus.test",
+		Subject: "Hi\r\nBcc: victim@kampus.test",
+		Text:    "body",
+	})
+	if err == nil {
+		t.Fatal("expected an error for a subject containing CRLF")
+	}
+	select {
+	case <-messages:
+		t.Fatal("message with an injected header was delivered")
+	default:
+	}
+}
@@ -18,6 +18,7 @@ type Users struct {
 	University    string    `pg:"university" json:"university" binding:"required"`
 	Status        string    `pg:"status" json:"status" binding:"required"`
 	IsAdmin       bool      `pg:"is_admin,default:false" json:"is_admin"`
+	StorageQuota  *int64    `pg:"storage_quota_bytes" json:"-"`
 	CreatedAt     time.Time `pg:"created_at,default:now()" json:"created_at"`
 }
 
@@ -26,12 +27,17 @@ type Payload struct {
 }
 
 type Forums struct {
-	ID          int       `json:"id"`
-	FID         uuid.UUID `json:"fid"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	CategoryID  int       `json:"category_id"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                    int       `json:"id"`
+	FID                   uuid.UUID `json:"fid"`
+	Title                 string    `json:"title"`
+	Description           string    `json:"description"`
+	CategoryID            int       `json:"category_id"`
+	IsPrivate             bool      `json:"is_private"`
+	TagPolicy             string    `json:"tag_policy"`
+	StorageQuota          *int64    `pg:"storage_quota_bytes" json:"-"`
+	MediaRelocationStatus string    `json:"media_relocation_status"`
+	MediaRelocationError  string    `json:"-"`
+	UpdatedAt             time.Time `json:"updated_at"`
 }
 
 type ForumMembers struct {
@@ -41,16 +47,77 @@ type ForumMembers struct {
 }
 
 type Posts struct {
-	ID        int       `json:"id"`
-	ForumID   uuid.UUID `json:"forum_id" form:"forum_id"`
-	UserID    uuid.UUID `json:"user_id"`
-	Title     string    `json:"title" form:"title"`
-	Body      string    `json:"body" form:"body"`
-	MediaURL  string    `json:"media_url"`
-	MediaType string    `json:"media_type"`
+	ID             int               `json:"id"`
+	ForumID        uuid.UUID         `json:"forum_id" form:"forum_id"`
+	UserID         uuid.UUID         `json:"user_id"`
+	Title          string            `json:"title" form:"title"`
+	Body           string            `json:"body" form:"body"`
+	BodyHTML       string            `pg:"body_html" json:"body_html"`
+	MediaURL       string            `json:"media_url"`
+	MediaType      string            `json:"media_type"`
+	IsLocked       bool              `json:"is_locked"`
+	PinPosition    *int              `json:"pin_position"`
+	IsAnnouncement bool              `json:"is_announcement"`
+	FlairID        *int              `json:"flair_id"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	EditedAt       *time.Time        `json:"edited_at"`
+	DeletedAt      time.Time         `pg:",soft_delete" json:"deleted_at,omitzero"`
+	DeletedBy      *uuid.UUID        `json:"deleted_by,omitempty"`
+	User           *Users            `pg:"rel:has-one,fk:user_id" json:"user"`
+	Poll           *Polls            `pg:"-" json:"poll,omitempty"`
+	Attachments    []PostAttachments `pg:"-" json:"attachments"`
+	Tags           []string          `pg:"-" json:"tags"`
+	Flair          *ForumFlairs      `pg:"-" json:"flair"`
+}
+
+type PostAttachments struct {
+	ID                int                  `json:"id"`
+	PostID            int                  `json:"post_id"`
+	Position          int                  `json:"position"`
+	URL               string               `json:"url"`
+	StorageKey        string               `json:"-"`
+	MediaType         string               `json:"media_type"`
+	MimeType          string               `json:"mime_type"`
+	SizeBytes         int64                `json:"size_bytes"`
+	Checksum          string               `json:"checksum"`
+	Width             int                  `json:"width,omitempty"`
+	Height            int                  `json:"height,omitempty"`
+	Duration          *float64             `json:"duration,omitempty"`
+	Caption           string               `pg:",use_zero" json:"caption"`
+	AltText           string               `pg:",use_zero" json:"alt_text"`
+	CreatedAt         time.Time            `json:"created_at"`
+	VariantsStatus    string               `json:"variants_status"`
+	VariantsAttempts  int                  `pg:",use_zero" json:"-"`
+	VariantsStartedAt *time.Time           `json:"-"`
+	ThumbnailURL      string               `pg:"-" json:"thumbnail_url,omitempty"`
+	Variants          []AttachmentVariants `pg:"-" json:"variants"`
+}
+
+type AttachmentVariants struct {
+	ID           int       `json:"id"`
+	AttachmentID int       `json:"attachment_id"`
+	Kind         string    `json:"kind"`
+	Format       string    `json:"format"`
+	URL          string    `json:"url"`
+	StorageKey   string    `json:"-"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	SizeBytes    int64     `json:"size_bytes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type MediaUploads struct {
+	ID        uuid.UUID `pg:"id,pk,type:uuid" json:"upload_id"`
+	UserID    uuid.UUID `json:"-"`
+	Filename  string    `json:"filename"`
+	SizeBytes int64     `json:"size_bytes"`
+	Offset    int64     `pg:"offset_bytes,use_zero" json:"offset"`
+	Checksum  string    `json:"checksum"`
+	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	User      *Users    `pg:"rel:has-one,fk:user_id" json:"user"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 type PostWithCounts struct {
@@ -58,16 +125,21 @@ type PostWithCounts struct {
 	Upvotes   int  `json:"upvotes"`
 	Downvotes int  `json:"downvotes"`
 	MyVote    *int `json:"my_vote"`
+	Saved     bool `json:"saved"`
 }
 
 type Comments struct {
-	ID              int       `pg:",pk" json:"id"`
-	PostID          int       `json:"post_id"`
-	UserID          uuid.UUID `json:"user_id"`
-	ParentCommentID int       `json:"parent_comment_id"`
-	Body            string    `json:"body"`
-	CreatedAt       time.Time `json:"created_at"`
-	User            *Users    `pg:"rel:has-one,fk:user_id" json:"user"`
+	ID              int        `pg:",pk" json:"id"`
+	PostID          int        `json:"post_id"`
+	UserID          uuid.UUID  `json:"user_id"`
+	ParentCommentID int        `json:"parent_comment_id"`
+	Body            string     `json:"body"`
+	BodyHTML        string     `pg:"body_html" json:"body_html"`
+	CreatedAt       time.Time  `json:"created_at"`
+	EditedAt        *time.Time `json:"edited_at"`
+	DeletedAt       time.Time  `pg:",soft_delete" json:"deleted_at,omitzero"`
+	DeletedBy       *uuid.UUID `json:"deleted_by,omitempty"`
+	User            *Users     `pg:"rel:has-one,fk:user_id" json:"user"`
 }
 
 type Categories struct {
@@ -82,3 +154,301 @@ type Votes struct {
 	CommentID *int      `json:"comment_id,omitempty"`
 	Value     int       `json:"value"`
 }
+
+type ForumBans struct {
+	ID        int        `json:"id"`
+	ForumID   uuid.UUID  `json:"forum_id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Type      string     `json:"type"`
+	Reason    string     `json:"reason"`
+	BannedBy  uuid.UUID  `json:"banned_by"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	User      *Users     `pg:"rel:has-one,fk:user_id" json:"user,omitempty"`
+}
+
+type Reports struct {
+	ID              int        `json:"id"`
+	ReporterID      uuid.UUID  `json:"reporter_id"`
+	TargetType      string     `json:"target_type"`
+	PostID          *int       `json:"post_id,omitempty"`
+	CommentID       *int       `json:"comment_id,omitempty"`
+	ReportedUserID  *uuid.UUID `json:"reported_user_id,omitempty"`
+	ForumID         *uuid.UUID `json:"forum_id,omitempty"`
+	Category        string     `json:"category"`
+	Reason          string     `json:"reason"`
+	ContentSnapshot string     `json:"content_snapshot"`
+	Status          string     `json:"status"`
+	Action          string     `json:"action,omitempty"`
+	ResolutionNote  string     `json:"resolution_note,omitempty"`
+	ResolvedBy      *uuid.UUID `json:"resolved_by,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	Reporter        *Users     `pg:"rel:has-one,fk:reporter_id" json:"reporter,omitempty"`
+}
+
+type AuditLogs struct {
+	ID         int         `json:"id"`
+	ActorID    uuid.UUID   `json:"actor_id"`
+	ForumID    *uuid.UUID  `json:"forum_id,omitempty"`
+	Action     string      `json:"action"`
+	TargetType string      `json:"target_type"`
+	TargetID   string      `json:"target_id"`
+	Before     interface{} `pg:"before,type:jsonb" json:"before"`
+	After      interface{} `pg:"after,type:jsonb" json:"after"`
+	Reason     string      `json:"reason"`
+	CreatedAt  time.Time   `json:"created_at"`
+	Actor      *Users      `pg:"rel:has-one,fk:actor_id" json:"actor,omitempty"`
+}
+
+type PostRevisions struct {
+	ID        int       `json:"id"`
+	PostID    int       `json:"post_id"`
+	Revision  int       `json:"revision"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	EditedBy  uuid.UUID `json:"edited_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CommentRevisions struct {
+	ID        int       `json:"id"`
+	CommentID int       `json:"comment_id"`
+	Revision  int       `json:"revision"`
+	Body      string    `json:"body"`
+	EditedBy  uuid.UUID `json:"edited_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Polls struct {
+	ID             int           `json:"id"`
+	PostID         int           `json:"post_id"`
+	Question       string        `json:"question"`
+	MultipleChoice bool          `json:"multiple_choice"`
+	IsAnonymous    bool          `json:"is_anonymous"`
+	ClosesAt       *time.Time    `json:"closes_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+	Options        []PollOptions `pg:"rel:has-many,join_fk:poll_id" json:"options"`
+	IsClosed       bool          `pg:"-" json:"is_closed"`
+	TotalVoters    int           `pg:"-" json:"total_voters"`
+	MyVotes        []int         `pg:"-" json:"my_votes"`
+}
+
+type PollOptions struct {
+	ID       int      `json:"id"`
+	PollID   int      `json:"poll_id"`
+	Position int      `json:"position"`
+	Text     string   `json:"text"`
+	Votes    int      `pg:"-" json:"votes"`
+	Voters   []string `pg:"-" json:"voters,omitempty"`
+}
+
+type PollVotes struct {
+	ID        int       `json:"id"`
+	PollID    int       `json:"poll_id"`
+	OptionID  int       `json:"option_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserBlocks struct {
+	BlockerID uuid.UUID `pg:",pk,type:uuid" json:"blocker_id"`
+	BlockedID uuid.UUID `pg:",pk,type:uuid" json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Blocked   *Users    `pg:"rel:has-one,fk:blocked_id" json:"blocked,omitempty"`
+}
+
+type Mentions struct {
+	ID            int       `json:"id"`
+	UserID        uuid.UUID `json:"user_id"`
+	MentionedByID uuid.UUID `pg:"mentioned_by" json:"mentioned_by"`
+	ForumID       uuid.UUID `json:"forum_id"`
+	PostID        int       `json:"post_id"`
+	CommentID     *int      `json:"comment_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	Author        *Users    `pg:"rel:has-one,fk:mentioned_by" json:"author,omitempty"`
+	Post          *Posts    `pg:"rel:has-one" json:"post,omitempty"`
+}
+
+type Notifications struct {
+	ID        int         `json:"id"`
+	UserID    uuid.UUID   `json:"user_id"`
+	ActorID   *uuid.UUID  `json:"actor_id"`
+	Type      string      `json:"type"`
+	ForumID   *uuid.UUID  `json:"forum_id,omitempty"`
+	PostID    *int        `json:"post_id,omitempty"`
+	CommentID *int        `json:"comment_id,omitempty"`
+	Data      interface{} `pg:"data,type:jsonb" json:"data,omitempty"`
+	ReadAt    *time.Time  `json:"read_at"`
+	CreatedAt time.Time   `json:"created_at"`
+	Actor     *Users      `pg:"rel:has-one,fk:actor_id" json:"actor,omitempty"`
+}
+
+type NotificationPreferences struct {
+	UserID  uuid.UUID `pg:",pk,type:uuid" json:"-"`
+	Type    string    `pg:",pk" json:"type"`
+	Enabled bool      `pg:",use_zero" json:"enabled"`
+}
+
+type ForumJoinRequests struct {
+	ID        int        `json:"id"`
+	ForumID   uuid.UUID  `json:"forum_id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Message   string     `json:"message"`
+	Status    string     `json:"status"`
+	DecidedBy *uuid.UUID `json:"decided_by,omitempty"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	User      *Users     `pg:"rel:has-one,fk:user_id" json:"user,omitempty"`
+}
+
+type EmailDigestSettings struct {
+	UserID     uuid.UUID  `pg:",pk,type:uuid" json:"-"`
+	Frequency  string     `json:"frequency"`
+	Language   string     `json:"language"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type Conversations struct {
+	ID            int                   `json:"id"`
+	IsGroup       bool                  `pg:",use_zero" json:"is_group"`
+	Title         string                `json:"title"`
+	DirectKey     *string               `json:"-"`
+	CreatedBy     uuid.UUID             `json:"created_by"`
+	CreatedAt     time.Time             `json:"created_at"`
+	LastMessageAt time.Time             `json:"last_message_at"`
+	Members       []ConversationMembers `pg:"-" json:"members"`
+	LastMessage   *Messages             `pg:"-" json:"last_message"`
+	UnreadCount   int                   `pg:"-" json:"unread_count"`
+}
+
+type ConversationMembers struct {
+	ConversationID    int        `pg:",pk" json:"conversation_id"`
+	UserID            uuid.UUID  `pg:",pk,type:uuid" json:"user_id"`
+	Role              string     `json:"role"`
+	LastReadMessageID int        `pg:",use_zero" json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at"`
+	JoinedAt          time.Time  `json:"joined_at"`
+	User              *Users     `pg:"rel:has-one,fk:user_id" json:"user,omitempty"`
+}
+
+type Messages struct {
+	ID             int                  `json:"id"`
+	ConversationID int                  `json:"conversation_id"`
+	SenderID       uuid.UUID            `json:"sender_id"`
+	Body           string               `json:"body"`
+	CreatedAt      time.Time            `json:"created_at"`
+	DeletedAt      *time.Time           `json:"deleted_at,omitempty"`
+	Sender         *Users               `pg:"rel:has-one,fk:sender_id" json:"sender,omitempty"`
+	Attachments    []MessageAttachments `pg:"-" json:"attachments"`
+}
+
+type MessageAttachments struct {
+	ID         int       `json:"id"`
+	MessageID  int       `json:"message_id"`
+	Position   int       `json:"position"`
+	URL        string    `pg:"-" json:"url"`
+	StorageKey string    `json:"-"`
+	MediaType  string    `json:"media_type"`
+	MimeType   string    `json:"mime_type"`
+	SizeBytes  int64     `json:"size_bytes"`
+	Checksum   string    `json:"checksum"`
+	Width      int       `json:"width,omitempty"`
+	Height     int       `json:"height,omitempty"`
+	Duration   *float64  `json:"duration,omitempty"`
+	Caption    string    `json:"caption"`
+	AltText    string    `json:"alt_text"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ForumChatMessages struct {
+	ID        int        `json:"id"`
+	ForumID   uuid.UUID  `json:"forum_id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *uuid.UUID `json:"deleted_by,omitempty"`
+	User      *Users     `pg:"rel:has-one,fk:user_id" json:"user,omitempty"`
+}
+
+type ForumWebhooks struct {
+	ID        int       `json:"id"`
+	ForumID   uuid.UUID `json:"forum_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `pg:",array" json:"events"`
+	Active    bool      `pg:",use_zero" json:"active"`
+	CreatedBy uuid.UUID `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WebhookDeliveries struct {
+	ID             int         `json:"id"`
+	WebhookID      int         `json:"webhook_id"`
+	Event          string      `json:"event"`
+	Payload        interface{} `pg:"payload,type:jsonb" json:"payload"`
+	Status         string      `json:"status"`
+	Attempts       int         `pg:",use_zero" json:"attempts"`
+	NextAttemptAt  *time.Time  `json:"next_attempt_at"`
+	ResponseStatus *int        `json:"response_status"`
+	ResponseBody   string      `pg:",use_zero" json:"response_body"`
+	Error          string      `pg:",use_zero" json:"error"`
+	DurationMs     int         `pg:",use_zero" json:"duration_ms"`
+	CreatedAt      time.Time   `json:"created_at"`
+	DeliveredAt    *time.Time  `json:"delivered_at"`
+}
+
+type BookmarkCollections struct {
+	ID        int       `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+	Position  int       `pg:",use_zero" json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ItemCount int       `pg:"-" json:"item_count"`
+}
+
+type Bookmarks struct {
+	ID           int             `json:"id"`
+	CollectionID int             `json:"collection_id"`
+	UserID       uuid.UUID       `json:"user_id"`
+	PostID       *int            `json:"post_id,omitempty"`
+	CommentID    *int            `json:"comment_id,omitempty"`
+	Position     int             `pg:",use_zero" json:"position"`
+	CreatedAt    time.Time       `json:"created_at"`
+	Post         *PostWithCounts `pg:"-" json:"post,omitempty"`
+	Comment      *Comments       `pg:"-" json:"comment,omitempty"`
+}
+
+type Tags struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PostTags struct {
+	PostID    int       `pg:",pk" json:"post_id"`
+	TagID     int       `pg:",pk" json:"tag_id"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ForumTags struct {
+	ForumID   uuid.UUID `pg:",pk,type:uuid" json:"forum_id"`
+	TagID     int       `pg:",pk" json:"tag_id"`
+	CreatedBy uuid.UUID `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ForumFlairs struct {
+	ID        int       `json:"id"`
+	ForumID   uuid.UUID `json:"forum_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	ModOnly   bool      `pg:",use_zero" json:"mod_only"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,67 @@
+package Storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	PrivatePrefix    = "private/"
+	QuarantinePrefix = PrivatePrefix + "quarantine/"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+type Backend interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+	List(ctx context.Context, fn func(Object) error) error
+}
+
+func IsPrivateKey(key string) bool {
+	return strings.HasPrefix(key, PrivatePrefix)
+}
+
+func NewFromEnv() (Backend, error) {
+	switch driver := strings.ToLower(os.Getenv("STORAGE_DRIVER")); driver {
+	case "", "local":
+		root := os.Getenv("LOCAL_STORAGE_DIR")
+		if root == "" {
+			root = "./uploads"
+		}
+		return NewLocal(root, "/uploads", "/files", []byte(signingSecret()))
+	case "s3", "minio":
+		return NewS3(context.Background(), S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			UseSSL:    os.Getenv("S3_USE_SSL") == "true",
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+}
+
+func signingSecret() string {
+	if secret := os.Getenv("MEDIA_SIGNING_SECRET"); secret != "" {
+		return secret
+	}
+	return os.Getenv("ACCESS_TOKEN_SECRET")
+}
@@ -4,11 +4,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/Ariffansyah/UnivTalk/Handlers"
+	"github.com/Ariffansyah/UnivTalk/Mailer"
 	"github.com/Ariffansyah/UnivTalk/Models"
+	"github.com/Ariffansyah/UnivTalk/Storage"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/go-pg/pg/v10"
@@ -44,14 +47,34 @@ func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
 	}
-	if _, err := os.Stat("./uploads"); os.IsNotExist(err) {
-		if err := os.MkdirAll("./uploads", 0755); err != nil {
-			log.Fatal("Failed to create uploads directory:", err)
-		}
+	store, err := Storage.NewFromEnv()
+	if err != nil {
+		log.Fatal("Failed to initialize media storage:", err)
+	}
+	Handlers.SetMediaStorage(store)
+	mail, err := Mailer.NewFromEnv()
+	switch err {
+	case nil:
+		Handlers.SetMailer(mail)
+	case Mailer.ErrNotConfigured:
+		log.Println("SMTP_ADDR not set, email digests disabled")
+	default:
+		log.Fatal("Failed to initialize mailer:", err)
 	}
+
 	router := gin.Default()
 	router.MaxMultipartMemory = 8 << 20
-	router.Static("/uploads", "./uploads")
+	if local, ok := store.(*Storage.Local); ok {
+		uploads := router.Group("/uploads", func(c *gin.Context) {
+			if strings.HasPrefix(path.Clean(c.Request.URL.Path)+"/", "/uploads/"+Storage.PrivatePrefix) {
+				c.AbortWithStatus(http.StatusNotFound)
+				return
+			}
+			c.Header("X-Content-Type-Options", "nosniff")
+			c.Next()
+		})
+		uploads.Static("/", local.Root)
+	}
 
 	db := pg.Connect(&pg.Options{
 		Addr:     os.Getenv("DB_ADDR"),
@@ -64,9 +87,31 @@ func main() {
 	}
 	defer db.Close()
 
+	if len(os.Args) > 1 && os.Args[1] == "gc-uploads" {
+		code := Handlers.RunOrphanSweepCommand(db, os.Args[2:])
+		db.Close()
+		os.Exit(code)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "send-digest" {
+		code := Handlers.RunSendDigestCommand(db, os.Args[2:])
+		db.Close()
+		os.Exit(code)
+	}
+
 	router.SetTrustedProxies([]string{"127.0.0.1"})
 	cacheData := cache.New(15*time.Minute, 30*time.Minute)
 
+	go Handlers.BackfillRenderedBodies(db)
+	go Handlers.StartBanExpiryJob(db, 5*time.Minute)
+	go Handlers.StartSoftDeletePurgeJob(db, 1*time.Hour)
+	go Handlers.StartMediaVariantWorker(db, 1*time.Minute)
+	go Handlers.StartMediaRelocationWorker(db, 10*time.Minute)
+	go Handlers.StartUploadExpiryJob(db, 1*time.Hour)
+	go Handlers.StartOrphanSweepJob(db, 24*time.Hour)
+	go Handlers.StartDigestJob(db, 1*time.Hour)
+	go Handlers.StartWebhookWorker(db, 30*time.Second)
+	go Handlers.StartRealtimeListener(db)
+
 	clientAddrEnv := os.Getenv("CLIENT_ADDR")
 	allowedOrigins := []string{}
 	if clientAddrEnv != "" {
@@ -74,17 +119,22 @@ func main() {
 			allowedOrigins = append(allowedOrigins, strings.TrimSpace(origin))
 		}
 	}
+	Handlers.SetAllowedOrigins(allowedOrigins)
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Upload-Offset", "Upload-Checksum"},
+		ExposeHeaders:    []string{"Content-Length", "Upload-Offset"},
 		AllowCredentials: true,
 		MaxAge:           12 * 60 * 60,
 	}))
 
 	router.GET("/universities", func(c *gin.Context) { Handlers.GetUniversities(c, cacheData) })
 	router.GET("/categories", func(c *gin.Context) { Handlers.GetCategories(c, db, cacheData) })
+	router.GET("/media/:attachment_id", func(c *gin.Context) { Handlers.ServeAttachmentVariant(c, db, cacheData) })
+	router.GET("/files/*key", func(c *gin.Context) { Handlers.ServeSignedMedia(c, db, cacheData) })
+	router.GET("/unsubscribe", func(c *gin.Context) { Handlers.ConfirmUnsubscribe(c, db, cacheData) })
+	router.POST("/unsubscribe", func(c *gin.Context) { Handlers.Unsubscribe(c, db, cacheData) })
 	router.POST("/signup", func(c *gin.Context) { Handlers.SignUp(c, db) })
 	router.POST("/signin", func(c *gin.Context) { Handlers.SignIn(c, db) })
 	router.POST("/signout", func(c *gin.Context) { Handlers.SignOut(c) })
@@ -108,6 +158,61 @@ func main() {
 		protected.POST("/profile/password", func(c *gin.Context) { Handlers.ChangePassword(c, db) })
 		protected.DELETE("/profile", func(c *gin.Context) { Handlers.DeleteAccount(c, db) })
 		protected.GET("/profile/:user_id", func(c *gin.Context) { Handlers.GetUserByID(c, db) })
+		protected.PUT("/profile/:user_id/storage-quota", func(c *gin.Context) { Handlers.SetUserStorageQuota(c, db, cacheData) })
+		protected.GET("/profile/digest", func(c *gin.Context) { Handlers.GetDigestSettings(c, db, cacheData) })
+		protected.PUT("/profile/digest", func(c *gin.Context) { Handlers.UpdateDigestSettings(c, db, cacheData) })
+		protected.GET("/profile/blocks", func(c *gin.Context) { Handlers.GetBlockedUsers(c, db, cacheData) })
+		protected.POST("/profile/:user_id/block", func(c *gin.Context) { Handlers.BlockUser(c, db, cacheData) })
+		protected.DELETE("/profile/:user_id/block", func(c *gin.Context) { Handlers.UnblockUser(c, db, cacheData) })
+		protected.GET("/mentions", func(c *gin.Context) { Handlers.GetMentions(c, db, cacheData) })
+		protected.GET("/realtime/events", func(c *gin.Context) { Handlers.StreamEvents(c, db, cacheData) })
+		protected.GET("/realtime/ws", func(c *gin.Context) { Handlers.ServeWebSocket(c, db, cacheData) })
+
+		notifications := protected.Group("/notifications")
+		{
+			notifications.GET("/", func(c *gin.Context) { Handlers.GetNotifications(c, db, cacheData) })
+			notifications.GET("/unread-count", func(c *gin.Context) { Handlers.GetUnreadNotificationCount(c, db, cacheData) })
+			notifications.POST("/read", func(c *gin.Context) { Handlers.MarkAllNotificationsRead(c, db, cacheData) })
+			notifications.POST("/:notification_id/read", func(c *gin.Context) { Handlers.MarkNotificationRead(c, db, cacheData) })
+			notifications.GET("/preferences", func(c *gin.Context) { Handlers.GetNotificationPreferences(c, db, cacheData) })
+			notifications.PUT("/preferences", func(c *gin.Context) { Handlers.UpdateNotificationPreferences(c, db, cacheData) })
+		}
+
+		messages := protected.Group("/messages")
+		{
+			messages.GET("/", func(c *gin.Context) { Handlers.GetConversations(c, db, cacheData) })
+			messages.POST("/", func(c *gin.Context) { Handlers.CreateConversation(c, db, cacheData) })
+			messages.GET("/unread-count", func(c *gin.Context) { Handlers.GetUnreadMessageCount(c, db, cacheData) })
+			messages.GET("/:conversation_id", func(c *gin.Context) { Handlers.GetConversation(c, db, cacheData) })
+			messages.PUT("/:conversation_id", func(c *gin.Context) { Handlers.UpdateConversation(c, db, cacheData) })
+			messages.POST("/:conversation_id/members", func(c *gin.Context) { Handlers.AddConversationMembers(c, db, cacheData) })
+			messages.DELETE("/:conversation_id/members/:user_id", func(c *gin.Context) { Handlers.RemoveConversationMember(c, db, cacheData) })
+			messages.GET("/:conversation_id/messages", func(c *gin.Context) { Handlers.GetMessages(c, db, cacheData) })
+			messages.POST("/:conversation_id/messages", func(c *gin.Context) { Handlers.SendMessage(c, db, cacheData) })
+			messages.DELETE("/:conversation_id/messages/:message_id", func(c *gin.Context) { Handlers.DeleteMessage(c, db, cacheData) })
+			messages.POST("/:conversation_id/read", func(c *gin.Context) { Handlers.MarkConversationRead(c, db, cacheData) })
+		}
+
+		bookmarks := protected.Group("/bookmarks")
+		{
+			bookmarks.GET("/", func(c *gin.Context) { Handlers.GetBookmarkCollections(c, db, cacheData) })
+			bookmarks.POST("/", func(c *gin.Context) { Handlers.CreateBookmarkCollection(c, db, cacheData) })
+			bookmarks.PUT("/order", func(c *gin.Context) { Handlers.ReorderBookmarkCollections(c, db, cacheData) })
+			bookmarks.DELETE("/saved", func(c *gin.Context) { Handlers.UnsaveContent(c, db, cacheData) })
+			bookmarks.PUT("/:collection_id", func(c *gin.Context) { Handlers.UpdateBookmarkCollection(c, db, cacheData) })
+			bookmarks.DELETE("/:collection_id", func(c *gin.Context) { Handlers.DeleteBookmarkCollection(c, db, cacheData) })
+			bookmarks.GET("/:collection_id/items", func(c *gin.Context) { Handlers.GetBookmarks(c, db, cacheData) })
+			bookmarks.POST("/:collection_id/items", func(c *gin.Context) { Handlers.SaveBookmark(c, db, cacheData) })
+			bookmarks.PUT("/:collection_id/items/order", func(c *gin.Context) { Handlers.ReorderBookmarks(c, db, cacheData) })
+			bookmarks.DELETE("/:collection_id/items/:bookmark_id", func(c *gin.Context) { Handlers.RemoveBookmark(c, db, cacheData) })
+		}
+
+		tags := protected.Group("/tags")
+		{
+			tags.GET("/autocomplete", func(c *gin.Context) { Handlers.AutocompleteTags(c, db, cacheData) })
+			tags.GET("/trending", func(c *gin.Context) { Handlers.GetTrendingTags(c, db, cacheData) })
+			tags.GET("/:tag_name", func(c *gin.Context) { Handlers.GetTagPosts(c, db, cacheData) })
+		}
 
 		forums := protected.Group("/forums")
 		{
@@ -115,12 +220,51 @@ func main() {
 			forums.POST("/", func(c *gin.Context) { Handlers.CreateForum(c, db, cacheData) })
 			forums.GET("/:forum_id", func(c *gin.Context) { Handlers.GetForumByID(c, db, cacheData) })
 			forums.PUT("/:forum_id", func(c *gin.Context) { Handlers.UpdateForum(c, db, cacheData) })
+			forums.PUT("/:forum_id/privacy", func(c *gin.Context) { Handlers.SetForumPrivacy(c, db, cacheData) })
+			forums.PUT("/:forum_id/storage-quota", func(c *gin.Context) { Handlers.SetForumStorageQuota(c, db, cacheData) })
 			forums.DELETE("/:forum_id", func(c *gin.Context) { Handlers.DeleteForum(c, db, cacheData) })
 			forums.POST("/:forum_id/join", func(c *gin.Context) { Handlers.JoinForum(c, db, cacheData) })
 			forums.POST("/:forum_id/leave", func(c *gin.Context) { Handlers.LeaveForum(c, db, cacheData) })
+			forums.GET("/:forum_id/join-requests", func(c *gin.Context) { Handlers.GetForumJoinRequests(c, db, cacheData) })
+			forums.POST("/:forum_id/join-requests/:request_id/approve", func(c *gin.Context) { Handlers.ApproveJoinRequest(c, db, cacheData) })
+			forums.POST("/:forum_id/join-requests/:request_id/reject", func(c *gin.Context) { Handlers.RejectJoinRequest(c, db, cacheData) })
 			forums.GET("/:forum_id/posts", func(c *gin.Context) { Handlers.GetForumPosts(c, db, cacheData) })
 			forums.GET("/:forum_id/members", func(c *gin.Context) { Handlers.GetForumMembersByID(c, db, cacheData) })
+			forums.GET("/:forum_id/tags", func(c *gin.Context) { Handlers.GetForumTags(c, db, cacheData) })
+			forums.PUT("/:forum_id/tags", func(c *gin.Context) { Handlers.UpdateForumTags(c, db, cacheData) })
+			forums.GET("/:forum_id/flairs", func(c *gin.Context) { Handlers.GetForumFlairs(c, db, cacheData) })
+			forums.POST("/:forum_id/flairs", func(c *gin.Context) { Handlers.CreateForumFlair(c, db, cacheData) })
+			forums.PUT("/:forum_id/flairs/:flair_id", func(c *gin.Context) { Handlers.UpdateForumFlair(c, db, cacheData) })
+			forums.DELETE("/:forum_id/flairs/:flair_id", func(c *gin.Context) { Handlers.DeleteForumFlair(c, db, cacheData) })
 			forums.GET("/user/:user_id", func(c *gin.Context) { Handlers.GetForumsByUserID(c, db, cacheData) })
+
+			forums.GET("/:forum_id/chat/ws", func(c *gin.Context) { Handlers.ServeForumChat(c, db, cacheData) })
+			forums.GET("/:forum_id/chat/messages", func(c *gin.Context) { Handlers.GetForumChatMessages(c, db, cacheData) })
+			forums.DELETE("/:forum_id/chat/messages/:message_id", func(c *gin.Context) { Handlers.DeleteForumChatMessage(c, db, cacheData) })
+
+			forums.GET("/:forum_id/webhooks", func(c *gin.Context) { Handlers.GetForumWebhooks(c, db, cacheData) })
+			forums.POST("/:forum_id/webhooks", func(c *gin.Context) { Handlers.CreateForumWebhook(c, db, cacheData) })
+			forums.PUT("/:forum_id/webhooks/:webhook_id", func(c *gin.Context) { Handlers.UpdateForumWebhook(c, db, cacheData) })
+			forums.DELETE("/:forum_id/webhooks/:webhook_id", func(c *gin.Context) { Handlers.DeleteForumWebhook(c, db, cacheData) })
+			forums.POST("/:forum_id/webhooks/:webhook_id/secret", func(c *gin.Context) { Handlers.RotateForumWebhookSecret(c, db, cacheData) })
+			forums.POST("/:forum_id/webhooks/:webhook_id/ping", func(c *gin.Context) { Handlers.PingForumWebhook(c, db, cacheData) })
+			forums.GET("/:forum_id/webhooks/:webhook_id/deliveries", func(c *gin.Context) { Handlers.GetWebhookDeliveries(c, db, cacheData) })
+			forums.POST("/:forum_id/webhooks/:webhook_id/deliveries/:delivery_id/redeliver", func(c *gin.Context) { Handlers.RedeliverWebhook(c, db, cacheData) })
+
+			forums.GET("/:forum_id/bans", func(c *gin.Context) { Handlers.GetForumBans(c, db, cacheData) })
+			forums.POST("/:forum_id/bans", func(c *gin.Context) { Handlers.BanForumUser(c, db, cacheData) })
+			forums.DELETE("/:forum_id/bans/:user_id", func(c *gin.Context) { Handlers.UnbanForumUser(c, db, cacheData) })
+			forums.GET("/:forum_id/reports", func(c *gin.Context) { Handlers.GetForumReports(c, db, cacheData) })
+			forums.GET("/:forum_id/audit-logs", func(c *gin.Context) { Handlers.GetForumAuditLogs(c, db, cacheData) })
+			forums.PUT("/:forum_id/pins", func(c *gin.Context) { Handlers.ReorderPinnedPosts(c, db, cacheData) })
+		}
+
+		uploads := protected.Group("/upload-sessions")
+		{
+			uploads.POST("/", func(c *gin.Context) { Handlers.CreateUpload(c, db, cacheData) })
+			uploads.GET("/:upload_id", func(c *gin.Context) { Handlers.GetUpload(c, db, cacheData) })
+			uploads.PATCH("/:upload_id", func(c *gin.Context) { Handlers.UploadChunk(c, db, cacheData) })
+			uploads.DELETE("/:upload_id", func(c *gin.Context) { Handlers.CancelUpload(c, db, cacheData) })
 		}
 
 		posts := protected.Group("/posts")
@@ -130,7 +274,21 @@ func main() {
 			posts.GET("/:post_id", func(c *gin.Context) { Handlers.GetForumPostsByID(c, db, cacheData) })
 			posts.PUT("/:post_id", func(c *gin.Context) { Handlers.UpdatePost(c, db, cacheData) })
 			posts.DELETE("/:post_id", func(c *gin.Context) { Handlers.DeletePost(c, db, cacheData) })
+			posts.POST("/:post_id/restore", func(c *gin.Context) { Handlers.RestorePost(c, db, cacheData) })
+			posts.PUT("/:post_id/flair", func(c *gin.Context) { Handlers.SetPostFlair(c, db, cacheData) })
+			posts.POST("/:post_id/pin", func(c *gin.Context) { Handlers.PinPost(c, db, cacheData) })
+			posts.DELETE("/:post_id/pin", func(c *gin.Context) { Handlers.UnpinPost(c, db, cacheData) })
+			posts.POST("/:post_id/lock", func(c *gin.Context) { Handlers.LockPost(c, db, cacheData) })
+			posts.DELETE("/:post_id/lock", func(c *gin.Context) { Handlers.UnlockPost(c, db, cacheData) })
+			posts.POST("/:post_id/announcement", func(c *gin.Context) { Handlers.AnnouncePost(c, db, cacheData) })
+			posts.DELETE("/:post_id/announcement", func(c *gin.Context) { Handlers.UnannouncePost(c, db, cacheData) })
 			posts.GET("/:post_id/comments", func(c *gin.Context) { Handlers.GetPostComments(c, db, cacheData) })
+			posts.GET("/:post_id/poll", func(c *gin.Context) { Handlers.GetPoll(c, db, cacheData) })
+			posts.POST("/:post_id/poll/vote", func(c *gin.Context) { Handlers.VotePoll(c, db, cacheData) })
+			posts.POST("/:post_id/poll/close", func(c *gin.Context) { Handlers.ClosePoll(c, db, cacheData) })
+
+			posts.GET("/:post_id/revisions", func(c *gin.Context) { Handlers.GetPostRevisions(c, db, cacheData) })
+			posts.GET("/:post_id/revisions/diff", func(c *gin.Context) { Handlers.GetPostRevisionDiff(c, db, cacheData) })
 
 			posts.POST("/:post_id/upvote", func(c *gin.Context) { Handlers.UpVotePost(c, db, cacheData) })
 			posts.POST("/:post_id/downvote", func(c *gin.Context) { Handlers.DownVotePost(c, db, cacheData) })
@@ -145,12 +303,25 @@ func main() {
 			comments.POST("/", func(c *gin.Context) { Handlers.CreateComment(c, db, cacheData) })
 			comments.PUT("/:comment_id", func(c *gin.Context) { Handlers.UpdateComment(c, db, cacheData) })
 			comments.DELETE("/:comment_id", func(c *gin.Context) { Handlers.DeleteComment(c, db, cacheData) })
+			comments.POST("/:comment_id/restore", func(c *gin.Context) { Handlers.RestoreComment(c, db, cacheData) })
+			comments.GET("/:comment_id/revisions", func(c *gin.Context) { Handlers.GetCommentRevisions(c, db, cacheData) })
+			comments.GET("/:comment_id/revisions/diff", func(c *gin.Context) { Handlers.GetCommentRevisionDiff(c, db, cacheData) })
 
 			comments.POST("/:comment_id/upvote", func(c *gin.Context) { Handlers.UpVoteComment(c, db, cacheData) })
 			comments.POST("/:comment_id/downvote", func(c *gin.Context) { Handlers.DownVoteComment(c, db, cacheData) })
 			comments.DELETE("/:comment_id/vote", func(c *gin.Context) { Handlers.RemoveVoteComment(c, db, cacheData) })
 			comments.GET("/:comment_id/vote", func(c *gin.Context) { Handlers.GetCommentVotes(c, db) })
 		}
+
+		reports := protected.Group("/reports")
+		{
+			reports.POST("/", func(c *gin.Context) { Handlers.CreateReport(c, db, cacheData) })
+			reports.GET("/", func(c *gin.Context) { Handlers.GetReports(c, db, cacheData) })
+			reports.POST("/:report_id/resolve", func(c *gin.Context) { Handlers.ResolveReport(c, db, cacheData) })
+		}
+
+		protected.GET("/audit-logs", func(c *gin.Context) { Handlers.GetAuditLogs(c, db, cacheData) })
+		protected.POST("/storage/gc", func(c *gin.Context) { Handlers.SweepOrphanedMedia(c, db, cacheData) })
 	}
 
 	router.Run()